@@ -0,0 +1,56 @@
+// Package auth authenticates API callers and carries the authenticated
+// principal in the request context.
+package auth
+
+import (
+	"context"
+	"errors"
+	"slices"
+)
+
+// Errors returned by authenticators. Wrapped errors carry the reason, which
+// is logged but never shown to the caller.
+var (
+	ErrInvalidCredentials = errors.New("invalid credentials") // Malformed, wrongly signed or unknown credentials.
+	ErrExpired            = errors.New("credentials expired") // Credentials that were valid but are past their expiry.
+)
+
+// Principal is an authenticated caller.
+type Principal struct {
+	Subject string   // Who the caller is, e.g. the "sub" claim of a token.
+	Roles   []string // Roles granted to the caller, consulted by authorization.
+	Scopes  []string // Scopes the credentials were issued for.
+	Method  string   // How the caller authenticated, e.g. "jwt" or "api_key".
+
+	// Permissions are granted to the credentials themselves rather than
+	// through a role, e.g. the scopes of an API key.
+	Permissions []string
+}
+
+// HasRole reports whether the principal was granted the role.
+func (p *Principal) HasRole(role string) bool {
+	return slices.Contains(p.Roles, role)
+}
+
+// Authenticator verifies the credentials sent with one authorization scheme,
+// i.e. the part of the Authorization header after the scheme name.
+type Authenticator interface {
+	Authenticate(ctx context.Context, credentials string) (*Principal, error)
+}
+
+// contextKey is the type of the context keys defined by this package.
+type contextKey int
+
+const principalKey contextKey = iota
+
+// WithPrincipal returns a copy of ctx carrying the principal.
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey, p)
+}
+
+// PrincipalFromContext returns the principal carried by ctx, or nil when the
+// request was not authenticated.
+func PrincipalFromContext(ctx context.Context) *Principal {
+	p, _ := ctx.Value(principalKey).(*Principal)
+	return p
+}
@@ -0,0 +1,140 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+)
+
+// KeySet holds the public keys of a JSON Web Key Set (RFC 7517), used to
+// verify RS256 and ES256 tokens. Keys are looked up by their "kid".
+type KeySet struct {
+	keys map[string]crypto.PublicKey
+}
+
+// jwk is the subset of a JSON Web Key needed for RSA and P-256 public keys.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`   // RSA modulus.
+	E   string `json:"e"`   // RSA public exponent.
+	Crv string `json:"crv"` // Elliptic curve.
+	X   string `json:"x"`   // EC point coordinates.
+	Y   string `json:"y"`
+}
+
+// LoadJWKS reads a key set from a local JWKS file.
+func LoadJWKS(path string) (*KeySet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := ParseJWKS(data)
+	if err != nil {
+		return nil, fmt.Errorf("JWKS file %s: %w", path, err)
+	}
+	return keys, nil
+}
+
+// ParseJWKS parses a key set. Keys that are not for signatures (a "use"
+// other than "sig") are skipped; unsupported key types are an error.
+func ParseJWKS(data []byte) (*KeySet, error) {
+	var doc struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	set := &KeySet{keys: make(map[string]crypto.PublicKey)}
+	for i, k := range doc.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("key %d (%q): %w", i, k.Kid, err)
+		}
+		if _, dup := set.keys[k.Kid]; dup {
+			return nil, fmt.Errorf("key %d: duplicate kid %q", i, k.Kid)
+		}
+		set.keys[k.Kid] = key
+	}
+	if len(set.keys) == 0 {
+		return nil, errors.New("no signing keys")
+	}
+	return set, nil
+}
+
+// Key returns the key with the ID. A token without a "kid" is accepted only
+// when the set holds a single key.
+func (s *KeySet) Key(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(s.keys) == 1 {
+		for _, key := range s.keys {
+			return key, true
+		}
+	}
+	key, ok := s.keys[kid]
+	return key, ok
+}
+
+// publicKey decodes the key material.
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeInt(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("modulus: %w", err)
+		}
+		e, err := decodeInt(k.E)
+		if err != nil || !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 {
+			return nil, errors.New("invalid exponent")
+		}
+		if n.BitLen() < 2048 {
+			return nil, fmt.Errorf("%d-bit RSA keys are too weak", n.BitLen())
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv) // ES256 is the only EC algorithm accepted.
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != 32 {
+			return nil, errors.New("invalid x coordinate")
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil || len(y) != 32 {
+			return nil, errors.New("invalid y coordinate")
+		}
+		point := append(append([]byte{4}, x...), y...) // Uncompressed SEC 1 encoding.
+		if _, err := ecdh.P256().NewPublicKey(point); err != nil {
+			return nil, errors.New("point is not on the curve")
+		}
+		key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		return key, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+// decodeInt decodes a base64url-encoded big-endian integer.
+func decodeInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, errors.New("empty value")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
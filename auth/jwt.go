@@ -0,0 +1,130 @@
+package auth
+
+import (
+	"Curd/config"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Signing algorithms accepted by JWTVerifier.
+const (
+	AlgHS256 = "HS256" // HMAC with a shared secret.
+	AlgRS256 = "RS256" // RSA keys from the JWKS file.
+	AlgES256 = "ES256" // P-256 keys from the JWKS file.
+)
+
+// minSecretLength is the shortest HS256 secret accepted, matching the hash size.
+const minSecretLength = 32
+
+// JWTConfig configures a JWTVerifier.
+type JWTConfig struct {
+	Issuer     string        // Required "iss" claim.
+	Audience   string        // Required entry of the "aud" claim.
+	HMACSecret []byte        // Verifies HS256 tokens; nil rejects them.
+	Keys       *KeySet       // Verifies RS256 and ES256 tokens; nil rejects them.
+	Leeway     time.Duration // Clock skew tolerated when checking "exp", "nbf" and "iat".
+}
+
+// JWTVerifier authenticates bearer tokens: JWTs signed with HS256, RS256 or
+// ES256 that were issued by the configured issuer for the configured
+// audience and have not expired.
+type JWTVerifier struct {
+	cfg    JWTConfig
+	parser *jwt.Parser
+}
+
+// claims are the JWT claims read into a Principal.
+type claims struct {
+	jwt.RegisteredClaims
+	Roles []string `json:"roles,omitempty"` // Roles granted to the subject.
+	Scope string   `json:"scope,omitempty"` // Space-separated scopes, as in OAuth 2.0.
+}
+
+// NewJWTVerifier returns a verifier for the configuration. At least one of
+// HMACSecret and Keys is required.
+func NewJWTVerifier(cfg JWTConfig) (*JWTVerifier, error) {
+	if cfg.Issuer == "" || cfg.Audience == "" {
+		return nil, errors.New("JWT issuer and audience are required")
+	}
+	var algs []string
+	if cfg.HMACSecret != nil {
+		if len(cfg.HMACSecret) < minSecretLength {
+			return nil, fmt.Errorf("HS256 secret must be at least %d bytes", minSecretLength)
+		}
+		algs = append(algs, AlgHS256)
+	}
+	if cfg.Keys != nil {
+		algs = append(algs, AlgRS256, AlgES256)
+	}
+	if len(algs) == 0 {
+		return nil, errors.New("an HS256 secret or a JWKS file is required")
+	}
+
+	return &JWTVerifier{
+		cfg: cfg,
+		parser: jwt.NewParser(
+			jwt.WithValidMethods(algs), // Never trust the token's own "alg", e.g. "none".
+			jwt.WithIssuer(cfg.Issuer),
+			jwt.WithAudience(cfg.Audience),
+			jwt.WithExpirationRequired(),
+			jwt.WithIssuedAt(),
+			jwt.WithLeeway(cfg.Leeway),
+		),
+	}, nil
+}
+
+// LoadJWTVerifier returns the verifier described by the configuration,
+// reading its JWKS file if one is set.
+func LoadJWTVerifier(cfg config.AuthConfig) (*JWTVerifier, error) {
+	jc := JWTConfig{Issuer: cfg.Issuer, Audience: cfg.Audience, Leeway: cfg.Leeway}
+	if cfg.HMACSecret != "" {
+		jc.HMACSecret = []byte(cfg.HMACSecret)
+	}
+	if cfg.JWKSFile != "" {
+		keys, err := LoadJWKS(cfg.JWKSFile)
+		if err != nil {
+			return nil, err
+		}
+		jc.Keys = keys
+	}
+	return NewJWTVerifier(jc)
+}
+
+// Authenticate verifies a token and returns its subject as the principal.
+func (v *JWTVerifier) Authenticate(_ context.Context, token string) (*Principal, error) {
+	var c claims
+	if _, err := v.parser.ParseWithClaims(token, &c, v.key); err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return nil, fmt.Errorf("%w: %v", ErrExpired, err)
+		}
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	}
+	if c.Subject == "" {
+		return nil, fmt.Errorf("%w: token has no subject", ErrInvalidCredentials)
+	}
+	return &Principal{
+		Subject: c.Subject,
+		Roles:   c.Roles,
+		Scopes:  strings.Fields(c.Scope),
+		Method:  "jwt",
+	}, nil
+}
+
+// key returns the key that verifies the token, chosen by its algorithm and
+// key ID. The parser has already rejected algorithms that are not configured.
+func (v *JWTVerifier) key(t *jwt.Token) (any, error) {
+	if t.Method.Alg() == AlgHS256 {
+		return v.cfg.HMACSecret, nil
+	}
+	kid, _ := t.Header["kid"].(string)
+	key, ok := v.cfg.Keys.Key(kid)
+	if !ok {
+		return nil, fmt.Errorf("unknown key %q", kid)
+	}
+	return key, nil // The jwt package rejects a key of the wrong type for the algorithm.
+}
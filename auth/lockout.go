@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"sync"
+	"time"
+)
+
+// maxLockoutEntries bounds the memory kept for failed attempts; beyond it,
+// entries whose window has passed are pruned.
+const maxLockoutEntries = 10_000
+
+// Lockout slows down password guessing: once an account has MaxAttempts
+// consecutive failures within Duration, it is locked for Duration and even
+// the right password is refused. State is kept in memory, so each replica
+// counts attempts separately.
+type Lockout struct {
+	MaxAttempts int           // Consecutive failures that lock the account.
+	Duration    time.Duration // How long failures are remembered and the lock lasts.
+
+	mu      sync.Mutex
+	entries map[string]*lockoutEntry // Failures by account, e.g. by email.
+}
+
+// lockoutEntry counts the recent failures of one account.
+type lockoutEntry struct {
+	failures    int
+	lastFailure time.Time
+	lockedUntil time.Time
+}
+
+// NewLockout returns a lockout after maxAttempts failures, lasting d.
+func NewLockout(maxAttempts int, d time.Duration) *Lockout {
+	return &Lockout{MaxAttempts: maxAttempts, Duration: d, entries: make(map[string]*lockoutEntry)}
+}
+
+// Locked reports whether the account is locked and for how much longer.
+func (l *Lockout) Locked(account string) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	e, ok := l.entries[account]
+	if !ok {
+		return 0, false
+	}
+	if remaining := time.Until(e.lockedUntil); remaining > 0 {
+		return remaining, true
+	}
+	return 0, false
+}
+
+// Fail records a failed attempt and reports whether it locked the account.
+func (l *Lockout) Fail(account string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if len(l.entries) >= maxLockoutEntries {
+		l.prune(now)
+	}
+	e, ok := l.entries[account]
+	if !ok || now.Sub(e.lastFailure) > l.Duration {
+		e = &lockoutEntry{} // Older failures no longer count.
+		l.entries[account] = e
+	}
+	e.failures++
+	e.lastFailure = now
+	if e.failures >= l.MaxAttempts {
+		e.failures = 0
+		e.lockedUntil = now.Add(l.Duration)
+		return true
+	}
+	return false
+}
+
+// Reset forgets the failures of an account after a successful attempt.
+func (l *Lockout) Reset(account string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.entries, account)
+}
+
+// prune drops entries that neither lock an account nor count any more.
+func (l *Lockout) prune(now time.Time) {
+	for account, e := range l.entries {
+		if now.After(e.lockedUntil) && now.Sub(e.lastFailure) > l.Duration {
+			delete(l.entries, account)
+		}
+	}
+}
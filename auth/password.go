@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// argon2id parameters for new hashes, following the OWASP recommendation
+// (19 MiB of memory, two passes, one thread). Hashes record their own
+// parameters, so raising these later does not break existing passwords.
+const (
+	argonMemory  = 19 * 1024 // KiB
+	argonTime    = 2
+	argonThreads = 1
+	argonKeyLen  = 32
+	argonSaltLen = 16
+)
+
+// errMalformedHash is returned for stored hashes that cannot be parsed.
+var errMalformedHash = errors.New("malformed password hash")
+
+// HashPassword hashes a password with argon2id and a random salt, returning
+// it in PHC string format: $argon2id$v=19$m=...,t=...,p=...$salt$hash.
+func HashPassword(password string) string {
+	salt := make([]byte, argonSaltLen)
+	rand.Read(salt)
+	key := argon2.IDKey([]byte(password), salt, argonTime, argonMemory, argonThreads, argonKeyLen)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, argonMemory, argonTime, argonThreads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key))
+}
+
+// dummyHash is verified against when there is no real hash, so the response
+// time does not reveal whether an account exists or has a password.
+var dummyHash = sync.OnceValue(func() string { return HashPassword("not a real password") })
+
+// VerifyPassword reports whether password matches the encoded hash. An empty
+// hash, for accounts without a password, never matches but costs the same
+// time as a real comparison.
+func VerifyPassword(encoded, password string) (bool, error) {
+	if encoded == "" {
+		VerifyPassword(dummyHash(), password)
+		return false, nil
+	}
+
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return false, errMalformedHash
+	}
+	var version int
+	var memory, passes uint32
+	var threads uint8
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return false, errMalformedHash
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &passes, &threads); err != nil {
+		return false, errMalformedHash
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false, errMalformedHash
+	}
+	want, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(want) == 0 {
+		return false, errMalformedHash
+	}
+
+	got := argon2.IDKey([]byte(password), salt, passes, memory, threads, uint32(len(want)))
+	return subtle.ConstantTimeCompare(got, want) == 1, nil
+}
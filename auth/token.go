@@ -0,0 +1,54 @@
+package auth
+
+import (
+	"Curd/config"
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// TokenIssuer signs the session tokens returned by a successful login. They
+// are HS256 JWTs with the issuer and audience the JWTVerifier expects, so
+// they are accepted like tokens from the identity provider.
+type TokenIssuer struct {
+	Secret   []byte        // HS256 signing secret, shared with the verifier.
+	Issuer   string        // "iss" claim.
+	Audience string        // "aud" claim.
+	TTL      time.Duration // Lifetime of a token.
+	Roles    []string      // Roles granted to every session.
+}
+
+// NewTokenIssuer returns the issuer described by the configuration. Logins
+// need the HS256 secret; with only a JWKS file the service cannot sign.
+func NewTokenIssuer(cfg config.AuthConfig) (*TokenIssuer, error) {
+	if cfg.HMACSecret == "" {
+		return nil, errors.New("auth.hmac_secret is required to issue session tokens")
+	}
+	return &TokenIssuer{
+		Secret:   []byte(cfg.HMACSecret),
+		Issuer:   cfg.Issuer,
+		Audience: cfg.Audience,
+		TTL:      cfg.SessionTTL,
+		Roles:    cfg.SessionRoles,
+	}, nil
+}
+
+// Issue signs a token for the subject, returning it with its expiry time.
+func (i *TokenIssuer) Issue(subject string) (string, time.Time, error) {
+	now := time.Now()
+	expires := now.Add(i.TTL)
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    i.Issuer,
+			Subject:   subject,
+			Audience:  jwt.ClaimStrings{i.Audience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expires),
+		},
+		Roles: i.Roles,
+	})
+	signed, err := token.SignedString(i.Secret)
+	return signed, expires, err
+}
@@ -0,0 +1,67 @@
+package auth
+
+import (
+	"Curd/model"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// verificationAudience is the "aud" of email verification tokens. The API
+// requires its own audience on bearer tokens, so a verification token can
+// never be used to call it.
+const verificationAudience = "curd:verify-email"
+
+// verificationClaims are the claims of an email verification token.
+type verificationClaims struct {
+	jwt.RegisteredClaims
+	Email string `json:"email"` // The address being verified.
+}
+
+// VerificationTokens signs and checks the tokens in email verification
+// links. A token names both the user and the address it was sent to, so a
+// link stops working once the user changes their email.
+type VerificationTokens struct {
+	Secret []byte        // HS256 signing secret; at least 32 bytes.
+	TTL    time.Duration // How long a link stays valid.
+}
+
+// Issue signs a token verifying the user's current email.
+func (v *VerificationTokens) Issue(user model.User) (string, error) {
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, verificationClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   strconv.Itoa(user.ID),
+			Audience:  jwt.ClaimStrings{verificationAudience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(v.TTL)),
+		},
+		Email: user.Email,
+	})
+	return token.SignedString(v.Secret)
+}
+
+// Verify checks a token, returning the user ID and email it verifies. An
+// expired token yields ErrExpired; any other bad token ErrInvalidCredentials.
+func (v *VerificationTokens) Verify(token string) (int, string, error) {
+	parser := jwt.NewParser(
+		jwt.WithValidMethods([]string{AlgHS256}),
+		jwt.WithAudience(verificationAudience),
+		jwt.WithExpirationRequired(),
+	)
+	var c verificationClaims
+	if _, err := parser.ParseWithClaims(token, &c, func(*jwt.Token) (any, error) { return v.Secret, nil }); err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return 0, "", fmt.Errorf("%w: %v", ErrExpired, err)
+		}
+		return 0, "", fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	}
+	id, err := strconv.Atoi(c.Subject)
+	if err != nil || c.Email == "" {
+		return 0, "", fmt.Errorf("%w: token names no user", ErrInvalidCredentials)
+	}
+	return id, c.Email, nil
+}
@@ -0,0 +1,136 @@
+// Package authz decides which operations an authenticated principal may
+// perform, based on the permissions granted to its roles.
+package authz
+
+import (
+	"Curd/auth"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Resources guarded by the policy.
+const (
+	ResourceUsers       = "users"
+	ResourceDeadLetters = "dead-letters"
+	ResourceAPIKeys     = "api-keys"
+)
+
+// Actions on resources.
+const (
+	ActionList   = "list"
+	ActionCreate = "create"
+	ActionRead   = "read"
+	ActionUpdate = "update"
+	ActionDelete = "delete"
+	ActionReplay = "replay"
+	ActionRotate = "rotate"
+	ActionRevoke = "revoke"
+)
+
+// Policy maps roles to the permissions they grant. A permission is written
+// "resource:action", e.g. "users:list"; either part may be "*", and "*" alone
+// grants everything. The suffix ":own" restricts it to records the principal
+// owns, e.g. "users:update:own" lets a user edit only their own record.
+type Policy struct {
+	roles map[string][]permission
+}
+
+// permission is one parsed permission.
+type permission struct {
+	resource string // Resource name, or "*".
+	action   string // Action name, or "*".
+	own      bool   // Only records owned by the principal.
+}
+
+// NewPolicy parses the permissions of each role.
+func NewPolicy(roles map[string][]string) (*Policy, error) {
+	p := &Policy{roles: make(map[string][]permission, len(roles))}
+	for role, perms := range roles {
+		for _, s := range perms {
+			perm, err := parsePermission(s)
+			if err != nil {
+				return nil, fmt.Errorf("role %q: %w", role, err)
+			}
+			p.roles[role] = append(p.roles[role], perm)
+		}
+	}
+	return p, nil
+}
+
+// parsePermission parses "*", "resource:action" or "resource:action:own".
+func parsePermission(s string) (permission, error) {
+	s = strings.TrimSpace(s)
+	if s == "*" {
+		return permission{resource: "*", action: "*"}, nil
+	}
+	parts := strings.Split(s, ":")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" || (len(parts) == 3 && parts[2] != "own") {
+		return permission{}, fmt.Errorf("invalid permission %q (want resource:action or resource:action:own)", s)
+	}
+	return permission{resource: parts[0], action: parts[1], own: len(parts) == 3}, nil
+}
+
+// Allowed reports whether the principal may perform the action on the
+// resource, through one of its roles or a permission granted directly.
+// Owner is the subject owning the record acted on, or "" when the action is
+// not on a single record (listing, creating); permissions limited to owned
+// records never match those.
+func (p *Policy) Allowed(principal *auth.Principal, resource, action, owner string) bool {
+	if principal == nil {
+		return false
+	}
+	grants := func(perm permission) bool {
+		return perm.matches(resource, action) && (!perm.own || (owner != "" && owner == principal.Subject))
+	}
+	for _, role := range principal.Roles {
+		for _, perm := range p.roles[role] {
+			if grants(perm) {
+				return true
+			}
+		}
+	}
+	for _, s := range principal.Permissions {
+		if perm, err := parsePermission(s); err == nil && grants(perm) {
+			return true
+		}
+	}
+	return false
+}
+
+// Holds reports whether the principal itself holds the permission s, so it
+// may grant it to others, e.g. as the scope of an API key. Wildcards in s
+// must be matched by wildcards held: "users:*" needs "users:*" or "*", not
+// just "users:list". A malformed permission is never held.
+func (p *Policy) Holds(principal *auth.Principal, s string) bool {
+	perm, err := parsePermission(s)
+	if err != nil || principal == nil {
+		return false
+	}
+	owner := ""
+	if perm.own {
+		owner = principal.Subject // Owned records are the narrower grant, so either kind covers it.
+	}
+	return p.Allowed(principal, perm.resource, perm.action, owner)
+}
+
+// ValidatePermission reports whether s is a well-formed permission.
+func ValidatePermission(s string) error {
+	_, err := parsePermission(s)
+	return err
+}
+
+// matches reports whether the permission covers the action on the resource.
+func (perm permission) matches(resource, action string) bool {
+	return (perm.resource == "*" || perm.resource == resource) && (perm.action == "*" || perm.action == action)
+}
+
+// Roles returns the names of the roles the policy declares, sorted.
+func (p *Policy) Roles() []string {
+	names := make([]string, 0, len(p.roles))
+	for role := range p.roles {
+		names = append(names, role)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,509 @@
+// Package config loads the service configuration.
+//
+// Every setting has a default and can be overridden, in increasing order of
+// precedence, by a YAML or TOML file, by an environment variable and by a
+// command-line flag. The file is named with the -config flag or the
+// CURD_CONFIG environment variable; its format follows its extension.
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Store backends.
+const (
+	BackendMemory   = "memory"
+	BackendPostgres = "postgres"
+)
+
+// Trace exporters.
+const (
+	ExporterNone   = "none"
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+	ExporterFile   = "file"
+)
+
+// Config is the complete service configuration.
+type Config struct {
+	HTTP         HTTPConfig
+	Store        StoreConfig
+	Notifiers    NotifiersConfig
+	Tracing      TracingConfig
+	Logging      LoggingConfig
+	Auth         AuthConfig
+	Authz        AuthzConfig
+	Verification VerificationConfig
+}
+
+// HTTPConfig configures the HTTP server.
+type HTTPConfig struct {
+	Addr            string        // Address to listen on, e.g. ":8080".
+	RequestTimeout  time.Duration // Deadline for each request's store calls; 0 disables it.
+	ReadTimeout     time.Duration // Deadline for reading a whole request, body included.
+	WriteTimeout    time.Duration // Deadline for writing a response; should exceed RequestTimeout.
+	IdleTimeout     time.Duration // How long keep-alive connections wait for the next request.
+	ShutdownTimeout time.Duration // Deadline for draining requests and background work on shutdown.
+}
+
+// StoreConfig selects and configures the user store.
+type StoreConfig struct {
+	Backend        string // BackendMemory or BackendPostgres.
+	DSN            string // PostgreSQL connection string; required for BackendPostgres.
+	MigrateOnStart bool   // Apply pending migrations before serving.
+}
+
+// NotifiersConfig toggles and configures the notification channels.
+type NotifiersConfig struct {
+	FCM            FCMConfig
+	Email          EmailConfig
+	DeadLetterFile string // File keeping permanently failed deliveries; empty keeps them in memory.
+}
+
+// FCMConfig configures Firebase Cloud Messaging push notifications.
+type FCMConfig struct {
+	Enabled         bool
+	CredentialsFile string // Firebase service account JSON file.
+	Topic           string // Topic the notifications are published to.
+}
+
+// EmailConfig configures SendGrid emails.
+type EmailConfig struct {
+	Enabled        bool
+	SendGridAPIKey string
+}
+
+// TracingConfig configures OpenTelemetry tracing.
+type TracingConfig struct {
+	Exporter    string  // ExporterNone, ExporterOTLP, ExporterStdout or ExporterFile.
+	Endpoint    string  // OTLP/HTTP collector endpoint, e.g. "localhost:4318"; empty uses the OTEL_EXPORTER_OTLP_* variables.
+	Insecure    bool    // Send OTLP without TLS.
+	File        string  // Output file for ExporterFile.
+	ServiceName string  // service.name resource attribute.
+	SampleRatio float64 // Fraction of new traces recorded, from 0 to 1; child spans follow their parent.
+}
+
+// LoggingConfig configures the structured logger.
+type LoggingConfig struct {
+	Level     string // "debug", "info", "warn" or "error".
+	Format    string // "json" or "text".
+	RedactPII bool   // Mask emails, names and credentials in log lines.
+}
+
+// AuthConfig configures the authentication of API callers with JWT bearer tokens.
+type AuthConfig struct {
+	Enabled    bool          // Require a valid bearer token on every route except the probes and /metrics.
+	Issuer     string        // Required "iss" claim.
+	Audience   string        // Required "aud" claim.
+	HMACSecret string        // Shared secret verifying HS256 tokens; at least 32 bytes.
+	JWKSFile   string        // Local JWKS file with the public keys verifying RS256 and ES256 tokens.
+	Leeway     time.Duration // Clock skew tolerated when checking token expiry.
+
+	SessionTTL       time.Duration // Lifetime of the tokens issued by POST /auth/login.
+	SessionRoles     []string      // Roles granted to sessions started with a password.
+	MaxLoginAttempts int           // Consecutive password failures that lock an account.
+	LockoutDuration  time.Duration // How long a locked account stays locked.
+}
+
+// AuthzConfig configures which operations authenticated callers may perform.
+type AuthzConfig struct {
+	// Roles maps each role, as named in the "roles" token claim, to the
+	// permissions it grants, e.g. "users:list" or "users:update:own".
+	Roles map[string][]string
+}
+
+// VerificationConfig configures the email verification links sent to new
+// and changed addresses.
+type VerificationConfig struct {
+	Enabled bool          // Email a verification link when a user is created or changes their email.
+	Secret  string        // Shared secret signing the links; at least 32 bytes.
+	TTL     time.Duration // How long a link stays valid.
+	URL     string        // Public address of GET /users/verify that the links point at.
+}
+
+// Default returns the configuration used when nothing overrides it: a
+// PostgreSQL store, whose DSN must still be given, with every notifier
+// disabled. Select the memory backend to run without any external dependency.
+func Default() *Config {
+	return &Config{
+		HTTP: HTTPConfig{
+			Addr:            ":8080",
+			RequestTimeout:  10 * time.Second,
+			ReadTimeout:     15 * time.Second,
+			WriteTimeout:    30 * time.Second,
+			IdleTimeout:     2 * time.Minute,
+			ShutdownTimeout: 30 * time.Second,
+		},
+		Store: StoreConfig{
+			Backend:        BackendPostgres,
+			MigrateOnStart: true,
+		},
+		Notifiers: NotifiersConfig{
+			FCM:            FCMConfig{Topic: "user-updates"},
+			DeadLetterFile: "dead_letters.json",
+		},
+		Tracing: TracingConfig{
+			Exporter:    ExporterNone,
+			ServiceName: "curd",
+			SampleRatio: 1,
+		},
+		Logging: LoggingConfig{
+			Level:     "info",
+			Format:    "json",
+			RedactPII: true,
+		},
+		Auth: AuthConfig{
+			Audience:         "curd",
+			Leeway:           30 * time.Second,
+			SessionTTL:       time.Hour,
+			SessionRoles:     []string{"user"},
+			MaxLoginAttempts: 5,
+			LockoutDuration:  15 * time.Minute,
+		},
+		Authz: AuthzConfig{
+			Roles: map[string][]string{
+				"admin":  {"*"},                                  // Everything.
+				"user":   {"users:read:own", "users:update:own"}, // Their own record only.
+				"reader": {"users:list"},                         // Read-only service accounts.
+			},
+		},
+		Verification: VerificationConfig{
+			TTL: 24 * time.Hour,
+			URL: "http://localhost:8080/users/verify",
+		},
+	}
+}
+
+// field binds one setting to its file key, environment variable and flag.
+type field struct {
+	key    string              // Dotted key in the file, also used as the flag name.
+	env    string              // Environment variable.
+	usage  string              // Flag help text.
+	value  flag.Value          // Setter bound to the Config field.
+	redact func(string) string // Hides secrets when the configuration is dumped; nil shows the value.
+}
+
+// fields lists every setting of c.
+func (c *Config) fields() []field {
+	return []field{
+		{key: "http.addr", env: "CURD_HTTP_ADDR", usage: "address to listen on", value: (*stringValue)(&c.HTTP.Addr)},
+		{key: "http.request_timeout", env: "CURD_HTTP_REQUEST_TIMEOUT", usage: "deadline for each request's store calls", value: (*durationValue)(&c.HTTP.RequestTimeout)},
+		{key: "http.read_timeout", env: "CURD_HTTP_READ_TIMEOUT", usage: "deadline for reading a request", value: (*durationValue)(&c.HTTP.ReadTimeout)},
+		{key: "http.write_timeout", env: "CURD_HTTP_WRITE_TIMEOUT", usage: "deadline for writing a response", value: (*durationValue)(&c.HTTP.WriteTimeout)},
+		{key: "http.idle_timeout", env: "CURD_HTTP_IDLE_TIMEOUT", usage: "keep-alive idle timeout", value: (*durationValue)(&c.HTTP.IdleTimeout)},
+		{key: "http.shutdown_timeout", env: "CURD_HTTP_SHUTDOWN_TIMEOUT", usage: "deadline for a graceful shutdown", value: (*durationValue)(&c.HTTP.ShutdownTimeout)},
+		{key: "store.backend", env: "CURD_STORE_BACKEND", usage: "user store backend: memory or postgres", value: (*stringValue)(&c.Store.Backend)},
+		{key: "store.dsn", env: "CURD_STORE_DSN", usage: "PostgreSQL connection string", value: (*stringValue)(&c.Store.DSN), redact: redactDSN},
+		{key: "store.migrate_on_start", env: "CURD_STORE_MIGRATE_ON_START", usage: "apply pending migrations before serving", value: (*boolValue)(&c.Store.MigrateOnStart)},
+		{key: "notifiers.fcm.enabled", env: "CURD_FCM_ENABLED", usage: "send push notifications through FCM", value: (*boolValue)(&c.Notifiers.FCM.Enabled)},
+		{key: "notifiers.fcm.credentials_file", env: "CURD_FCM_CREDENTIALS_FILE", usage: "Firebase service account JSON file", value: (*stringValue)(&c.Notifiers.FCM.CredentialsFile)},
+		{key: "notifiers.fcm.topic", env: "CURD_FCM_TOPIC", usage: "FCM topic for user notifications", value: (*stringValue)(&c.Notifiers.FCM.Topic)},
+		{key: "notifiers.email.enabled", env: "CURD_EMAIL_ENABLED", usage: "send emails through SendGrid", value: (*boolValue)(&c.Notifiers.Email.Enabled)},
+		{key: "notifiers.email.sendgrid_api_key", env: "SENDGRID_API_KEY", usage: "SendGrid API key", value: (*stringValue)(&c.Notifiers.Email.SendGridAPIKey), redact: redactSecret},
+		{key: "tracing.exporter", env: "CURD_TRACING_EXPORTER", usage: "trace exporter: none, otlp, stdout or file", value: (*stringValue)(&c.Tracing.Exporter)},
+		{key: "tracing.endpoint", env: "CURD_TRACING_ENDPOINT", usage: "OTLP/HTTP collector endpoint", value: (*stringValue)(&c.Tracing.Endpoint)},
+		{key: "tracing.insecure", env: "CURD_TRACING_INSECURE", usage: "send OTLP traces without TLS", value: (*boolValue)(&c.Tracing.Insecure)},
+		{key: "tracing.file", env: "CURD_TRACING_FILE", usage: "file the file exporter writes spans to", value: (*stringValue)(&c.Tracing.File)},
+		{key: "tracing.service_name", env: "CURD_TRACING_SERVICE_NAME", usage: "service name reported with traces", value: (*stringValue)(&c.Tracing.ServiceName)},
+		{key: "tracing.sample_ratio", env: "CURD_TRACING_SAMPLE_RATIO", usage: "fraction of traces recorded, from 0 to 1", value: (*floatValue)(&c.Tracing.SampleRatio)},
+		{key: "logging.level", env: "CURD_LOG_LEVEL", usage: "minimum log level: debug, info, warn or error", value: (*stringValue)(&c.Logging.Level)},
+		{key: "logging.format", env: "CURD_LOG_FORMAT", usage: "log format: json or text", value: (*stringValue)(&c.Logging.Format)},
+		{key: "logging.redact_pii", env: "CURD_LOG_REDACT_PII", usage: "mask emails, names and credentials in logs", value: (*boolValue)(&c.Logging.RedactPII)},
+		{key: "auth.enabled", env: "CURD_AUTH_ENABLED", usage: "require JWT bearer tokens on the API", value: (*boolValue)(&c.Auth.Enabled)},
+		{key: "auth.issuer", env: "CURD_AUTH_ISSUER", usage: "required token issuer (iss)", value: (*stringValue)(&c.Auth.Issuer)},
+		{key: "auth.audience", env: "CURD_AUTH_AUDIENCE", usage: "required token audience (aud)", value: (*stringValue)(&c.Auth.Audience)},
+		{key: "auth.hmac_secret", env: "CURD_AUTH_HMAC_SECRET", usage: "shared secret verifying HS256 tokens", value: (*stringValue)(&c.Auth.HMACSecret), redact: redactSecret},
+		{key: "auth.jwks_file", env: "CURD_AUTH_JWKS_FILE", usage: "JWKS file with keys verifying RS256 and ES256 tokens", value: (*stringValue)(&c.Auth.JWKSFile)},
+		{key: "auth.leeway", env: "CURD_AUTH_LEEWAY", usage: "clock skew tolerated when checking token expiry", value: (*durationValue)(&c.Auth.Leeway)},
+		{key: "auth.session_ttl", env: "CURD_AUTH_SESSION_TTL", usage: "lifetime of the tokens issued by /auth/login", value: (*durationValue)(&c.Auth.SessionTTL)},
+		{key: "auth.session_roles", env: "CURD_AUTH_SESSION_ROLES", usage: "comma-separated roles granted to password sessions", value: (*listValue)(&c.Auth.SessionRoles)},
+		{key: "auth.max_login_attempts", env: "CURD_AUTH_MAX_LOGIN_ATTEMPTS", usage: "password failures that lock an account", value: (*intValue)(&c.Auth.MaxLoginAttempts)},
+		{key: "auth.lockout_duration", env: "CURD_AUTH_LOCKOUT_DURATION", usage: "how long a locked account stays locked", value: (*durationValue)(&c.Auth.LockoutDuration)},
+		{key: "authz.roles", env: "CURD_AUTHZ_ROLES", usage: `permissions of each role, e.g. "admin=*;reader=users:list"`, value: (*rolesValue)(&c.Authz.Roles)},
+		{key: "verification.enabled", env: "CURD_VERIFICATION_ENABLED", usage: "email verification links to new and changed addresses", value: (*boolValue)(&c.Verification.Enabled)},
+		{key: "verification.secret", env: "CURD_VERIFICATION_SECRET", usage: "shared secret signing verification links", value: (*stringValue)(&c.Verification.Secret), redact: redactSecret},
+		{key: "verification.ttl", env: "CURD_VERIFICATION_TTL", usage: "how long a verification link stays valid", value: (*durationValue)(&c.Verification.TTL)},
+		{key: "verification.url", env: "CURD_VERIFICATION_URL", usage: "public address of /users/verify used in the links", value: (*stringValue)(&c.Verification.URL)},
+		{key: "notifiers.dead_letter_file", env: "CURD_DEAD_LETTER_FILE", usage: "file keeping failed notifications; empty keeps them in memory", value: (*stringValue)(&c.Notifiers.DeadLetterFile)},
+	}
+}
+
+// Load builds the configuration from the defaults, the configuration file,
+// the environment (read through getenv) and the command-line args, and
+// validates it. It returns the arguments left after the flags.
+func Load(args []string, getenv func(string) string) (*Config, []string, error) {
+	c := Default()
+	fields := c.fields()
+
+	// Parse the flags first to find the file, but apply them last.
+	set := make(map[string]string)
+	fs := flag.NewFlagSet("curd", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	configFile := fs.String("config", getenv("CURD_CONFIG"), "YAML or TOML configuration file")
+	for _, f := range fields {
+		_, isBool := f.value.(*boolValue)
+		fs.Var(pendingValue{key: f.key, set: set, isBool: isBool}, f.key, f.usage)
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	if *configFile != "" {
+		values, err := readFile(*configFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := c.apply(values, "config file "+*configFile); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	env := make(map[string]string)
+	for _, f := range fields {
+		if v := getenv(f.env); v != "" {
+			env[f.key] = v
+		}
+	}
+	if err := c.apply(env, "environment"); err != nil {
+		return nil, nil, err
+	}
+	if err := c.apply(set, "flag"); err != nil {
+		return nil, nil, err
+	}
+
+	if err := c.Validate(); err != nil {
+		return nil, nil, err
+	}
+	return c, fs.Args(), nil
+}
+
+// apply sets the fields named by the keys of values, rejecting unknown keys.
+func (c *Config) apply(values map[string]string, source string) error {
+	byKey := make(map[string]field)
+	for _, f := range c.fields() {
+		byKey[f.key] = f
+	}
+	var errs []error
+	replaced := make(map[string]bool) // Map settings whose earlier entries were cleared by this source.
+	for key, raw := range values {
+		f, ok := byKey[key]
+		if !ok {
+			// Settings holding a map take one key per entry, e.g. authz.roles.admin.
+			// The entries given by a source replace the whole map rather than merge
+			// into it, so a role left out of the file is not granted by default.
+			parent, entry, _ := cutLast(key, ".")
+			if m, isMap := byKey[parent].value.(mapValue); isMap {
+				if !replaced[parent] {
+					m.Reset()
+					replaced[parent] = true
+				}
+				if err := m.SetEntry(entry, raw); err != nil {
+					errs = append(errs, fmt.Errorf("%s: %s: %w", source, key, err))
+				}
+				continue
+			}
+			errs = append(errs, fmt.Errorf("%s: unknown setting %q", source, key))
+			continue
+		}
+		if err := f.value.Set(raw); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s: %w", source, key, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Validate reports every setting that is missing or inconsistent.
+func (c *Config) Validate() error {
+	var errs []error
+	if c.HTTP.Addr == "" {
+		errs = append(errs, errors.New("http.addr is required"))
+	}
+	if c.HTTP.RequestTimeout < 0 || c.HTTP.ReadTimeout < 0 || c.HTTP.WriteTimeout < 0 || c.HTTP.IdleTimeout < 0 {
+		errs = append(errs, errors.New("http timeouts must not be negative"))
+	}
+	if c.HTTP.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("http.shutdown_timeout must be positive"))
+	}
+	switch c.Store.Backend {
+	case BackendMemory:
+	case BackendPostgres:
+		if c.Store.DSN == "" {
+			errs = append(errs, errors.New("store.dsn is required for the postgres backend"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("store.backend must be %q or %q, got %q", BackendMemory, BackendPostgres, c.Store.Backend))
+	}
+	if c.Notifiers.FCM.Enabled && c.Notifiers.FCM.CredentialsFile == "" {
+		errs = append(errs, errors.New("notifiers.fcm.credentials_file is required when FCM is enabled"))
+	}
+	if c.Notifiers.FCM.Enabled && c.Notifiers.FCM.Topic == "" {
+		errs = append(errs, errors.New("notifiers.fcm.topic is required when FCM is enabled"))
+	}
+	if c.Notifiers.Email.Enabled && c.Notifiers.Email.SendGridAPIKey == "" {
+		errs = append(errs, errors.New("notifiers.email.sendgrid_api_key is required when email is enabled"))
+	}
+	switch c.Tracing.Exporter {
+	case ExporterNone, ExporterOTLP, ExporterStdout:
+	case ExporterFile:
+		if c.Tracing.File == "" {
+			errs = append(errs, errors.New("tracing.file is required for the file exporter"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("tracing.exporter must be one of none, otlp, stdout or file, got %q", c.Tracing.Exporter))
+	}
+	switch strings.ToLower(c.Logging.Level) {
+	case "debug", "info", "warn", "error":
+	default:
+		errs = append(errs, fmt.Errorf("logging.level must be debug, info, warn or error, got %q", c.Logging.Level))
+	}
+	switch strings.ToLower(c.Logging.Format) {
+	case "json", "text":
+	default:
+		errs = append(errs, fmt.Errorf("logging.format must be json or text, got %q", c.Logging.Format))
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		errs = append(errs, errors.New("tracing.sample_ratio must be between 0 and 1"))
+	}
+	if c.Auth.Enabled {
+		if c.Auth.Issuer == "" || c.Auth.Audience == "" {
+			errs = append(errs, errors.New("auth.issuer and auth.audience are required when auth is enabled"))
+		}
+		if c.Auth.HMACSecret == "" && c.Auth.JWKSFile == "" {
+			errs = append(errs, errors.New("auth.hmac_secret or auth.jwks_file is required when auth is enabled"))
+		}
+		if c.Auth.HMACSecret != "" && len(c.Auth.HMACSecret) < 32 {
+			errs = append(errs, errors.New("auth.hmac_secret must be at least 32 bytes"))
+		}
+	}
+	if c.Auth.Leeway < 0 {
+		errs = append(errs, errors.New("auth.leeway must not be negative"))
+	}
+	if c.Auth.SessionTTL <= 0 || c.Auth.LockoutDuration <= 0 {
+		errs = append(errs, errors.New("auth.session_ttl and auth.lockout_duration must be positive"))
+	}
+	if c.Auth.MaxLoginAttempts < 1 {
+		errs = append(errs, errors.New("auth.max_login_attempts must be at least 1"))
+	}
+	if c.Auth.Enabled && len(c.Authz.Roles) == 0 {
+		errs = append(errs, errors.New("authz.roles must declare at least one role when auth is enabled"))
+	}
+	if c.Verification.Enabled {
+		if len(c.Verification.Secret) < 32 {
+			errs = append(errs, errors.New("verification.secret of at least 32 bytes is required when verification is enabled"))
+		}
+		if c.Verification.TTL <= 0 {
+			errs = append(errs, errors.New("verification.ttl must be positive"))
+		}
+		if u, err := url.Parse(c.Verification.URL); err != nil || !u.IsAbs() {
+			errs = append(errs, fmt.Errorf("verification.url must be an absolute URL, got %q", c.Verification.URL))
+		}
+		if !c.Notifiers.Email.Enabled {
+			errs = append(errs, errors.New("notifiers.email must be enabled to send verification links"))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// String dumps the configuration one "key = value" line per setting, with
+// secrets redacted, so it is safe to log.
+func (c *Config) String() string {
+	var b strings.Builder
+	for _, f := range c.fields() {
+		fmt.Fprintf(&b, "%s = %q\n", f.key, f.display())
+	}
+	return b.String()
+}
+
+// LogValue logs the configuration as a group of settings, with secrets redacted.
+func (c *Config) LogValue() slog.Value {
+	var attrs []slog.Attr
+	for _, f := range c.fields() {
+		attrs = append(attrs, slog.String(f.key, f.display()))
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// display returns the value of the setting as dumped, with secrets redacted.
+func (f field) display() string {
+	v := f.value.String()
+	if f.redact != nil && v != "" {
+		v = f.redact(v)
+	}
+	return v
+}
+
+// Usage writes the flags and environment variables Load understands.
+func Usage(w io.Writer) {
+	fmt.Fprintf(w, "  -config FILE\n\tYAML or TOML configuration file (env CURD_CONFIG)\n")
+	for _, f := range Default().fields() {
+		fmt.Fprintf(w, "  -%s\n\t%s (env %s, default %q)\n", f.key, f.usage, f.env, f.value.String())
+	}
+}
+
+// cutLast slices s around the last instance of sep.
+func cutLast(s, sep string) (before, after string, found bool) {
+	if i := strings.LastIndex(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
+
+// readFile reads a YAML or TOML configuration file, chosen by its extension,
+// into flattened dotted keys.
+func readFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var tree map[string]any
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &tree)
+	case ".toml":
+		err = toml.Unmarshal(data, &tree)
+	default:
+		return nil, fmt.Errorf("config file %s: unsupported format %q (use .yaml, .yml or .toml)", path, ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("config file %s: %w", path, err)
+	}
+
+	values := make(map[string]string)
+	flatten("", tree, values)
+	return values, nil
+}
+
+// flatten turns nested tables into dotted keys, e.g. {store: {dsn: x}} -> store.dsn=x,
+// and lists into comma-separated values.
+func flatten(prefix string, tree map[string]any, out map[string]string) {
+	for k, v := range tree {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+		switch v := v.(type) {
+		case map[string]any:
+			flatten(key, v, out)
+		case []any:
+			items := make([]string, len(v))
+			for i, item := range v {
+				items[i] = fmt.Sprint(item)
+			}
+			out[key] = strings.Join(items, ",")
+		default:
+			out[key] = fmt.Sprint(v)
+		}
+	}
+}
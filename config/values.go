@@ -0,0 +1,163 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// stringValue, boolValue, intValue, floatValue, durationValue and listValue implement flag.Value on a Config field.
+type (
+	stringValue   string
+	boolValue     bool
+	intValue      int
+	floatValue    float64
+	durationValue time.Duration
+	listValue     []string // Comma-separated.
+)
+
+func (v *stringValue) Set(s string) error { *v = stringValue(s); return nil }
+func (v *stringValue) String() string     { return string(*v) }
+
+func (v *boolValue) Set(s string) error {
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return err
+	}
+	*v = boolValue(b)
+	return nil
+}
+func (v *boolValue) String() string   { return strconv.FormatBool(bool(*v)) }
+func (v *boolValue) IsBoolFlag() bool { return true } // Allow "-flag" without "=true".
+
+func (v *intValue) Set(s string) error {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return err
+	}
+	*v = intValue(n)
+	return nil
+}
+func (v *intValue) String() string { return strconv.Itoa(int(*v)) }
+
+func (v *floatValue) Set(s string) error {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return err
+	}
+	*v = floatValue(f)
+	return nil
+}
+func (v *floatValue) String() string { return strconv.FormatFloat(float64(*v), 'g', -1, 64) }
+
+func (v *durationValue) Set(s string) error {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*v = durationValue(d)
+	return nil
+}
+func (v *durationValue) String() string { return time.Duration(*v).String() }
+
+func (v *listValue) Set(s string) error {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	*v = items
+	return nil
+}
+func (v *listValue) String() string { return strings.Join(*v, ",") }
+
+// mapValue is a flag.Value holding a map whose entries can also be set one
+// at a time, from file keys such as authz.roles.admin.
+type mapValue interface {
+	Reset() // Removes every entry, before a source sets its own.
+	SetEntry(key, s string) error
+}
+
+// rolesValue implements flag.Value on the role permissions, written
+// "role=perm,perm;role=perm". Setting it replaces every role; setting an
+// entry replaces a single role.
+type rolesValue map[string][]string
+
+func (v *rolesValue) Set(s string) error {
+	roles := make(rolesValue)
+	for _, def := range strings.Split(s, ";") {
+		if strings.TrimSpace(def) == "" {
+			continue
+		}
+		role, perms, ok := strings.Cut(def, "=")
+		if !ok {
+			return fmt.Errorf("role %q: missing \"=\" before its permissions", strings.TrimSpace(def))
+		}
+		if err := roles.SetEntry(strings.TrimSpace(role), perms); err != nil {
+			return err
+		}
+	}
+	*v = roles
+	return nil
+}
+
+func (v *rolesValue) Reset() { *v = make(rolesValue) }
+
+func (v *rolesValue) SetEntry(role, s string) error {
+	if role == "" {
+		return errors.New("empty role name")
+	}
+	if *v == nil {
+		*v = make(rolesValue)
+	}
+	var perms []string
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			perms = append(perms, p)
+		}
+	}
+	(*v)[role] = perms
+	return nil
+}
+
+func (v *rolesValue) String() string {
+	roles := make([]string, 0, len(*v))
+	for role, perms := range *v {
+		roles = append(roles, role+"="+strings.Join(perms, ","))
+	}
+	sort.Strings(roles)
+	return strings.Join(roles, ";")
+}
+
+// pendingValue records a flag's raw value so Load can apply it after the
+// file and the environment.
+type pendingValue struct {
+	key    string
+	set    map[string]string
+	isBool bool
+}
+
+func (v pendingValue) Set(s string) error { v.set[v.key] = s; return nil }
+func (v pendingValue) String() string     { return "" }
+func (v pendingValue) IsBoolFlag() bool   { return v.isBool }
+
+// redactSecret hides a secret entirely.
+func redactSecret(string) string { return "[REDACTED]" }
+
+// dsnPassword matches the password in a key=value PostgreSQL connection string.
+var dsnPassword = regexp.MustCompile(`(password\s*=\s*)('(?:[^'\\]|\\.)*'|\S+)`)
+
+// redactDSN hides the password in a connection string, keeping the host and
+// database visible for troubleshooting. Both URL and key=value forms are handled.
+func redactDSN(dsn string) string {
+	if u, err := url.Parse(dsn); err == nil && u.Scheme != "" {
+		return u.Redacted()
+	}
+	return dsnPassword.ReplaceAllString(dsn, "${1}[REDACTED]")
+}
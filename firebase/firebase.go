@@ -2,7 +2,7 @@ package firebase
 
 import (
 	"context"
-	"log"
+	"fmt"
 
 	firebase "firebase.google.com/go"
 	"firebase.google.com/go/messaging"
@@ -16,15 +16,16 @@ var FirebaseApp *firebase.App
 var FCMClient *messaging.Client
 
 // InitFirebase initializes the Firebase app and FCM client using the provided credentials file path.
-func InitFirebase(credentialsFilePath string) {
+// It returns an error if either cannot be initialized.
+func InitFirebase(credentialsFilePath string) error {
 	// Create an option to specify the credentials file for Firebase initialization.
 	opt := option.WithCredentialsFile(credentialsFilePath)
 
 	// Initialize the Firebase app with the provided credentials.
 	app, err := firebase.NewApp(context.Background(), nil, opt)
 	if err != nil {
-		// Log a fatal error and terminate the program if Firebase app initialization fails.
-		log.Fatalf("error initializing Firebase app: %v", err)
+		// Report the failure to the caller instead of terminating the program.
+		return fmt.Errorf("error initializing Firebase app: %w", err)
 	}
 	// Assign the initialized app to the global FirebaseApp variable.
 	FirebaseApp = app
@@ -32,9 +33,10 @@ func InitFirebase(credentialsFilePath string) {
 	// Initialize the Firebase Cloud Messaging (FCM) client from the app instance.
 	client, err := app.Messaging(context.Background())
 	if err != nil {
-		// Log a fatal error and terminate the program if FCM client initialization fails.
-		log.Fatalf("error initializing FCM client: %v", err)
+		// Report the failure to the caller instead of terminating the program.
+		return fmt.Errorf("error initializing FCM client: %w", err)
 	}
 	// Assign the initialized client to the global FCMClient variable.
 	FCMClient = client
+	return nil
 }
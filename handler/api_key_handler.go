@@ -0,0 +1,192 @@
+package handler
+
+import (
+	"Curd/auth"
+	"Curd/authz"
+	"Curd/logging"
+	"Curd/model"
+	"Curd/store"
+	"Curd/validation"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// APIKeyHandler lets administrators manage the API keys of machine clients
+// under /admin/api-keys.
+type APIKeyHandler struct {
+	Store  store.APIKeyStoreInterface // Where keys are persisted.
+	Policy *authz.Policy              // Decides who may manage keys; nil allows everyone.
+}
+
+// apiKeyRequest is the body of a request creating a key.
+type apiKeyRequest struct {
+	Name      string     `json:"name" normalize:"trim" validate:"required,max=100"`
+	Scopes    []string   `json:"scopes" validate:"required"` // Permissions such as "users:list".
+	ExpiresAt *time.Time `json:"expires_at"`                 // Omit for a key that never expires.
+}
+
+// apiKeyResponse is a key as returned when it is created or rotated, the
+// only times its secret is shown.
+type apiKeyResponse struct {
+	model.APIKey
+	Key string `json:"key"` // The secret to send as "Authorization: ApiKey <key>".
+}
+
+// ServeHTTP routes incoming HTTP requests to the appropriate handler function.
+func (h *APIKeyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/admin/api-keys":
+		h.CreateAPIKey(w, r) // Handle creating a key.
+	case r.Method == http.MethodGet && r.URL.Path == "/admin/api-keys":
+		h.ListAPIKeys(w, r) // Handle listing keys.
+	case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/rotate"):
+		h.RotateAPIKey(w, r) // Handle replacing the secret of a key.
+	case r.Method == http.MethodDelete && strings.HasPrefix(r.URL.Path, "/admin/api-keys/"):
+		h.RevokeAPIKey(w, r) // Handle revoking a key.
+	default:
+		writeProblem(w, r, http.StatusNotFound, "No route for "+r.Method+" "+r.URL.Path) // Return 404 for unsupported routes.
+	}
+}
+
+// CreateAPIKey handles creating a key. The response carries the secret,
+// which cannot be retrieved again.
+func (h *APIKeyHandler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, h.Policy, authz.ResourceAPIKeys, authz.ActionCreate, 0) {
+		return // Return 403 if not allowed.
+	}
+
+	// Decode and validate the request body, including every scope.
+	var req apiKeyRequest
+	err := strictDecode(r.Body, &req)
+	if err == nil {
+		err = validateAPIKeyRequest(&req)
+	}
+	if err != nil {
+		writeDecodeError(w, r, err) // Return 422 listing every invalid field, 400 for malformed JSON.
+		return
+	}
+	if !authorizeScopes(w, r, h.Policy, req.Scopes) {
+		return // Return 403 for scopes the caller does not hold.
+	}
+
+	secret, prefix, hash := auth.NewAPIKey()
+	key, err := h.Store.CreateAPIKey(r.Context(), model.APIKey{Name: req.Name, Prefix: prefix, Hash: hash, Scopes: req.Scopes, ExpiresAt: req.ExpiresAt})
+	if err != nil {
+		writeAPIKeyStoreError(w, r, err)
+		return
+	}
+	logging.FromContext(r.Context()).Info("API key created", "key", key, "scopes", key.Scopes)
+
+	w.Header().Set("Location", "/admin/api-keys/"+strconv.Itoa(key.ID))
+	w.Header().Set("Cache-Control", "no-store") // The secret must not be cached.
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(apiKeyResponse{APIKey: key, Key: secret})
+}
+
+// validateAPIKeyRequest normalizes and validates a create request.
+func validateAPIKeyRequest(req *apiKeyRequest) error {
+	var errs validation.Errors
+	if err := validation.Struct(req); err != nil {
+		errors.As(err, &errs)
+	}
+	for _, scope := range req.Scopes {
+		if authz.ValidatePermission(scope) != nil {
+			errs = append(errs, validation.FieldError{Field: "scopes", Code: "permission", Message: "must be permissions such as users:list, got " + strconv.Quote(scope)})
+		}
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		errs = append(errs, validation.FieldError{Field: "expires_at", Code: "future", Message: "must be in the future"})
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// ListAPIKeys handles listing every key, without their secrets.
+func (h *APIKeyHandler) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, h.Policy, authz.ResourceAPIKeys, authz.ActionList, 0) {
+		return // Return 403 if not allowed.
+	}
+	keys, err := h.Store.ListAPIKeys(r.Context())
+	if err != nil {
+		writeAPIKeyStoreError(w, r, err)
+		return
+	}
+	if keys == nil {
+		keys = []model.APIKey{} // Encode an empty list as [] rather than null.
+	}
+	json.NewEncoder(w).Encode(keys)
+}
+
+// RotateAPIKey handles replacing the secret of the key at
+// /admin/api-keys/{id}/rotate. The old secret stops working immediately.
+func (h *APIKeyHandler) RotateAPIKey(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, h.Policy, authz.ResourceAPIKeys, authz.ActionRotate, 0) {
+		return // Return 403 if not allowed.
+	}
+	id, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/admin/api-keys/"), "/rotate"))
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, "Invalid API key ID") // Return 400 for invalid ID.
+		return
+	}
+
+	// The new secret carries the key's scopes, so the caller must hold them too.
+	existing, err := h.Store.GetAPIKey(r.Context(), id)
+	if err != nil {
+		writeAPIKeyStoreError(w, r, err)
+		return
+	}
+	if !authorizeScopes(w, r, h.Policy, existing.Scopes) {
+		return // Return 403 for scopes the caller does not hold.
+	}
+
+	secret, prefix, hash := auth.NewAPIKey()
+	key, err := h.Store.RotateAPIKey(r.Context(), id, prefix, hash)
+	if err != nil {
+		writeAPIKeyStoreError(w, r, err)
+		return
+	}
+	logging.FromContext(r.Context()).Info("API key rotated", "key", key)
+
+	w.Header().Set("Cache-Control", "no-store") // The secret must not be cached.
+	json.NewEncoder(w).Encode(apiKeyResponse{APIKey: key, Key: secret})
+}
+
+// RevokeAPIKey handles revoking the key at /admin/api-keys/{id}. The key is
+// kept, marked revoked, so past use can still be attributed to it.
+func (h *APIKeyHandler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, h.Policy, authz.ResourceAPIKeys, authz.ActionRevoke, 0) {
+		return // Return 403 if not allowed.
+	}
+	id, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/admin/api-keys/"))
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, "Invalid API key ID") // Return 400 for invalid ID.
+		return
+	}
+
+	key, err := h.Store.RevokeAPIKey(r.Context(), id)
+	if err != nil {
+		writeAPIKeyStoreError(w, r, err)
+		return
+	}
+	logging.FromContext(r.Context()).Info("API key revoked", "key", key)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeAPIKeyStoreError maps an error returned by the API key store onto an
+// HTTP status code and writes it to the response as a problem.
+func writeAPIKeyStoreError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, store.ErrAPIKeyNotFound):
+		writeProblem(w, r, http.StatusNotFound, "API key not found") // Return 404 if the key does not exist.
+	case errors.Is(err, store.ErrAPIKeyRevoked):
+		writeProblem(w, r, http.StatusConflict, "The API key was revoked; create a new one instead.") // Return 409 for a revoked key.
+	default:
+		writeStoreError(w, r, err) // Timeouts, outages and anything else are reported like user store errors.
+	}
+}
@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"Curd/auth"
+	"Curd/logging"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Authenticate returns middleware that requires every request to carry an
+// Authorization header with one of the schemes, e.g. "Bearer <token>", and
+// puts the authenticated principal into the request context. Requests whose
+// route pattern is public, such as the probes, pass through untouched.
+// Failures get a 401 problem and a WWW-Authenticate challenge.
+func Authenticate(schemes map[string]auth.Authenticator, public map[string]bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if public[r.Pattern] {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			scheme, credentials, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+			authenticator := lookupScheme(schemes, scheme)
+			if authenticator == nil || credentials == "" {
+				writeUnauthenticated(w, r, schemes, "", "Send credentials in the Authorization header.")
+				return
+			}
+
+			principal, err := authenticator.Authenticate(r.Context(), strings.TrimSpace(credentials))
+			if err != nil && !errors.Is(err, auth.ErrInvalidCredentials) && !errors.Is(err, auth.ErrExpired) {
+				logging.FromContext(r.Context()).Error("authentication unavailable", "scheme", scheme, "error", err)
+				writeProblem(w, r, http.StatusServiceUnavailable, "Credentials cannot be checked right now.") // Return 503 so the client retries.
+				return
+			}
+			if err != nil {
+				logging.FromContext(r.Context()).Info("authentication failed", "scheme", scheme, "error", err)
+				detail := "The credentials are invalid."
+				if errors.Is(err, auth.ErrExpired) {
+					detail = "The credentials have expired."
+				}
+				writeUnauthenticated(w, r, schemes, scheme, detail)
+				return
+			}
+
+			ctx := auth.WithPrincipal(r.Context(), principal)
+			ctx = logging.WithLogger(ctx, logging.FromContext(ctx).With("subject", principal.Subject))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// lookupScheme finds the authenticator of a scheme; scheme names are case-insensitive.
+func lookupScheme(schemes map[string]auth.Authenticator, scheme string) auth.Authenticator {
+	for name, a := range schemes {
+		if strings.EqualFold(name, scheme) {
+			return a
+		}
+	}
+	return nil
+}
+
+// writeUnauthenticated writes the 401 problem with a challenge for every
+// scheme. The scheme whose credentials were rejected is flagged with
+// error="invalid_token" (RFC 6750).
+func writeUnauthenticated(w http.ResponseWriter, r *http.Request, schemes map[string]auth.Authenticator, rejected, detail string) {
+	names := make([]string, 0, len(schemes))
+	for name := range schemes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		challenge := name + ` realm="curd"`
+		if strings.EqualFold(name, rejected) {
+			challenge += `, error="invalid_token"`
+		}
+		w.Header().Add("WWW-Authenticate", challenge)
+	}
+	renderProblem(w, r, Problem{Type: problemUnauthenticated, Status: http.StatusUnauthorized, Detail: detail})
+}
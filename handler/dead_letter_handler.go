@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"Curd/authz"
+	"Curd/logging"
+	"Curd/notification"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// DeadLetterHandler lets operators inspect and replay notifications that
+// could not be delivered.
+type DeadLetterHandler struct {
+	Queue  *notification.Queue // Queue whose dead letters are exposed.
+	Policy *authz.Policy       // Decides who may inspect and replay; nil allows everyone.
+}
+
+// ServeHTTP routes incoming HTTP requests to the appropriate handler function.
+func (h *DeadLetterHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/admin/dead-letters":
+		h.ListDeadLetters(w, r) // Handle listing dead letters.
+	case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/replay"):
+		h.ReplayDeadLetter(w, r) // Handle replaying a dead letter by ID.
+	default:
+		writeProblem(w, r, http.StatusNotFound, "No route for "+r.Method+" "+r.URL.Path) // Return 404 for unsupported routes.
+	}
+}
+
+// ListDeadLetters handles listing every undelivered notification.
+func (h *DeadLetterHandler) ListDeadLetters(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, h.Policy, authz.ResourceDeadLetters, authz.ActionList, 0) {
+		return // Return 403 if not allowed.
+	}
+	letters, err := h.Queue.DeadLetters()
+	if err != nil {
+		logging.FromContext(r.Context()).Error("list dead letters failed", "error", err)
+		writeProblem(w, r, http.StatusInternalServerError, "Failed to list dead letters") // Return 500 for server error.
+		return
+	}
+	json.NewEncoder(w).Encode(letters)
+}
+
+// ReplayDeadLetter handles re-queueing the dead letter at /admin/dead-letters/{id}/replay.
+func (h *DeadLetterHandler) ReplayDeadLetter(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, h.Policy, authz.ResourceDeadLetters, authz.ActionReplay, 0) {
+		return // Return 403 if not allowed.
+	}
+	id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/admin/dead-letters/"), "/replay")
+
+	switch err := h.Queue.Replay(id); {
+	case errors.Is(err, notification.ErrDeadLetterNotFound):
+		writeProblem(w, r, http.StatusNotFound, "Dead letter not found") // Return 404 for an unknown ID.
+	case errors.Is(err, notification.ErrQueueFull), errors.Is(err, notification.ErrQueueClosed):
+		writeProblem(w, r, http.StatusServiceUnavailable, "Notification queue unavailable") // Return 503 so the operator retries later.
+	case err != nil:
+		logging.FromContext(r.Context()).Error("replay dead letter failed", "dead_letter_id", id, "error", err)
+		writeProblem(w, r, http.StatusInternalServerError, "Failed to replay dead letter") // Return 500 for server error.
+	default:
+		w.WriteHeader(http.StatusAccepted) // Delivery happens in the background.
+	}
+}
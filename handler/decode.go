@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"Curd/model"
+	"Curd/validation"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// errMalformedBody is returned when the request body is not a JSON object.
+var errMalformedBody = errors.New("request body must be a single JSON object")
+
+// decodeUser strictly decodes a user from the request body, then normalizes
+// and validates it. Unknown fields and rule violations are reported together
+// as validation.Errors.
+func decodeUser(r *http.Request) (model.User, error) {
+	var user model.User
+	if err := strictDecode(r.Body, &user); err != nil {
+		return model.User{}, err
+	}
+	return user, validation.Struct(&user)
+}
+
+// strictDecode decodes exactly one JSON value from body into v, rejecting
+// fields v does not have with validation.Errors.
+func strictDecode(body io.Reader, v any) error {
+	decoder := json.NewDecoder(body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		// The decoder reports unknown fields as `json: unknown field "name"`.
+		if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+			return validation.Errors{{Field: strings.Trim(field, `"`), Code: "unknown", Message: "is not a recognized field"}}
+		}
+		return errMalformedBody
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return errMalformedBody // Trailing data after the value.
+	}
+	return nil
+}
+
+// writeDecodeError writes the response for an error returned by decodeUser.
+func writeDecodeError(w http.ResponseWriter, r *http.Request, err error) {
+	var fieldErrs validation.Errors
+	if !errors.As(err, &fieldErrs) {
+		writeProblem(w, r, http.StatusBadRequest, err.Error()) // Return 400 for malformed JSON.
+		return
+	}
+	renderProblem(w, r, Problem{ // Return 422 listing every failing field.
+		Type:   problemValidation,
+		Status: http.StatusUnprocessableEntity,
+		Detail: "One or more fields are invalid.",
+		Errors: fieldErrs,
+	})
+}
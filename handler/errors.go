@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"Curd/logging"
+	"Curd/store"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// writeStoreError maps an error returned by the user store onto an HTTP status
+// code and writes it to the response as a problem.
+func writeStoreError(w http.ResponseWriter, r *http.Request, err error) {
+	var validationErr *store.ValidationError
+	var conflictErr *store.ConflictError
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		logging.FromContext(r.Context()).Info("request cancelled", "error", err) // The client went away, so nobody will read a response.
+	case errors.Is(err, context.DeadlineExceeded):
+		renderProblem(w, r, Problem{Type: problemTimeout, Status: http.StatusServiceUnavailable, Detail: "The request did not complete within the server deadline."}) // Return 503 when the server deadline passes.
+	case errors.Is(err, store.ErrVersionMismatch):
+		writeVersionMismatch(w, r) // Return 412 if the user changed since it was read.
+	case errors.Is(err, store.ErrNotFound):
+		writeProblem(w, r, http.StatusNotFound, "User not found") // Return 404 if the user does not exist.
+	case errors.As(err, &validationErr):
+		renderProblem(w, r, Problem{Type: problemValidation, Status: http.StatusBadRequest, Detail: validationErr.Error()}) // Return 400 for input the store rejected.
+	case errors.Is(err, store.ErrValidation):
+		renderProblem(w, r, Problem{Type: problemValidation, Status: http.StatusBadRequest, Detail: "The store rejected the input."})
+	case errors.As(err, &conflictErr):
+		writeConflict(w, r, conflictErr) // Return 409 for duplicate data.
+	case errors.Is(err, store.ErrConflict):
+		renderProblem(w, r, Problem{Type: problemConflict, Status: http.StatusConflict})
+	case errors.Is(err, store.ErrUnavailable):
+		logging.FromContext(r.Context()).Error("store unavailable", "error", err)
+		writeProblem(w, r, http.StatusServiceUnavailable, "The user store is temporarily unavailable.") // Return 503 during outages.
+	default:
+		logging.FromContext(r.Context()).Error("store error", "error", err)
+		writeProblem(w, r, http.StatusInternalServerError, "") // Return 500 for anything else, without leaking internals.
+	}
+}
+
+// writeConflict writes the 409 problem for a duplicate value, pointing at the
+// user that already holds it when the store knows which one that is.
+func writeConflict(w http.ResponseWriter, r *http.Request, conflictErr *store.ConflictError) {
+	p := Problem{Type: problemConflict, Status: http.StatusConflict, Detail: conflictErr.Error()}
+	if conflictErr.ExistingID != 0 {
+		p.Existing = "/users/" + strconv.Itoa(conflictErr.ExistingID)
+		p.Detail = fmt.Sprintf("A user with %s %q already exists.", conflictErr.Field, conflictErr.Value)
+		w.Header().Set("Location", p.Existing) // Let clients follow the link to the existing user.
+	}
+	renderProblem(w, r, p)
+}
+
+// writeVersionMismatch writes the 412 problem for a failed If-Match precondition.
+func writeVersionMismatch(w http.ResponseWriter, r *http.Request) {
+	renderProblem(w, r, Problem{
+		Type:   problemVersion,
+		Status: http.StatusPreconditionFailed,
+		Detail: "The user was modified since it was read; fetch it again and retry.",
+	})
+}
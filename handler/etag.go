@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"Curd/model"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// etag returns the strong entity tag for a user, derived from its version.
+func etag(user model.User) string {
+	return `"` + strconv.Itoa(user.Version) + `"`
+}
+
+// etagMatches reports whether an If-Match header value lists the given
+// entity tag, using the strong comparison RFC 9110 requires for writes.
+// "*" matches any existing user. Weak tags (W/"...") never match.
+func etagMatches(header, tag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || candidate == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// etagMatchesWeak reports whether an If-None-Match header value lists the
+// given entity tag, using the weak comparison RFC 9110 requires there: W/"3"
+// matches "3", since a cache may have weakened the tag it was sent.
+func etagMatchesWeak(header, tag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(tag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// expectedVersion evaluates the request's If-Match header against the
+// current user. It returns the version the store must still hold for the
+// write to go ahead (0 when the request is unconditional) and false if the
+// precondition already fails. "*" only asks for the user to exist, which
+// current shows, so it does not hold the write to a version.
+func expectedVersion(r *http.Request, current model.User) (int, bool) {
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "" {
+		return 0, true // Unconditional write.
+	}
+	if !etagMatches(ifMatch, etag(current)) {
+		return 0, false
+	}
+	if strings.TrimSpace(ifMatch) == "*" {
+		return 0, true // Any version will do.
+	}
+	return current.Version, true
+}
@@ -0,0 +1,39 @@
+package handler
+
+import (
+	"Curd/health"
+	"encoding/json"
+	"net/http"
+)
+
+// HealthHandler serves the liveness and readiness probes used by orchestrators.
+type HealthHandler struct {
+	Registry *health.Registry // Dependencies probed for readiness; nil reports ready with no components.
+}
+
+// Liveness handles GET /healthz. It only reports that the process is serving
+// requests, without probing dependencies, so an outage of the database does
+// not get every replica restarted.
+func (h *HealthHandler) Liveness(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, health.Report{Status: health.StatusUp, Components: []health.ComponentStatus{}})
+}
+
+// Readiness handles GET /readyz. It probes every registered dependency and
+// answers 503 if any of them is down, so traffic is routed elsewhere.
+func (h *HealthHandler) Readiness(w http.ResponseWriter, r *http.Request) {
+	report := health.Report{Status: health.StatusUp, Components: []health.ComponentStatus{}}
+	if h.Registry != nil {
+		report = h.Registry.Check(r.Context())
+	}
+	writeHealth(w, report)
+}
+
+// writeHealth writes a health report with 200 when it is up and 503 otherwise.
+func writeHealth(w http.ResponseWriter, report health.Report) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store") // Probes must always see the current state.
+	if report.Status != health.StatusUp {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"Curd/auth"
+	"Curd/logging"
+	"Curd/store"
+	"Curd/validation"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LoginHandler exchanges a user's email and password for a session token
+// at POST /auth/login. The token is a bearer token accepted by the API like
+// those from the identity provider.
+type LoginHandler struct {
+	Store   store.UserStoreInterface // Where users and their password hashes are kept.
+	Issuer  *auth.TokenIssuer        // Signs the session tokens.
+	Lockout *auth.Lockout            // Locks accounts after repeated failures; nil disables it.
+}
+
+// loginRequest is the body of a login.
+type loginRequest struct {
+	Email    string `json:"email" normalize:"trim" validate:"required"`
+	Password string `json:"password" validate:"required"`
+}
+
+// loginResponse is an OAuth 2.0 style token response.
+type loginResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"` // Always "Bearer".
+	ExpiresIn   int    `json:"expires_in"` // Lifetime of the token in seconds.
+}
+
+// ServeHTTP handles a login. Unknown emails and wrong passwords get the
+// same answer in about the same time, so responses do not reveal which
+// emails have accounts.
+func (h *LoginHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Decode and validate the request body.
+	var req loginRequest
+	err := strictDecode(r.Body, &req)
+	if err == nil {
+		err = validation.Struct(&req)
+	}
+	if err != nil {
+		writeDecodeError(w, r, err) // Return 422 listing every invalid field, 400 for malformed JSON.
+		return
+	}
+
+	// Refuse locked accounts before doing any work.
+	account := strings.ToLower(req.Email)
+	if h.Lockout != nil {
+		if remaining, locked := h.Lockout.Locked(account); locked {
+			logging.FromContext(r.Context()).Warn("login refused", "audit", true, "reason", "locked", "remote_addr", r.RemoteAddr)
+			writeLocked(w, r, remaining) // Return 429 while the account is locked.
+			return
+		}
+	}
+
+	// Look the user up. A missing user is checked against an empty hash,
+	// which costs as much as a real one.
+	user, err := h.Store.GetUserByEmail(r.Context(), req.Email)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		writeStoreError(w, r, err) // Return 503 if the store is down.
+		return
+	}
+	ok, err := auth.VerifyPassword(user.PasswordHash, req.Password)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("stored password hash is invalid", "user_id", user.ID, "error", err)
+		ok = false // Treat a corrupt hash like a wrong password.
+	}
+	if !ok {
+		locked := h.Lockout != nil && h.Lockout.Fail(account)
+		logging.FromContext(r.Context()).Warn("login failed", "audit", true, "user_id", user.ID, "locked", locked, "remote_addr", r.RemoteAddr)
+		renderProblem(w, r, Problem{Type: problemUnauthenticated, Status: http.StatusUnauthorized, Detail: "The email or password is incorrect."})
+		return
+	}
+	if h.Lockout != nil {
+		h.Lockout.Reset(account)
+	}
+
+	// Issue the session token.
+	token, expires, err := h.Issuer.Issue(strconv.Itoa(user.ID))
+	if err != nil {
+		logging.FromContext(r.Context()).Error("signing session token failed", "error", err)
+		writeProblem(w, r, http.StatusInternalServerError, "The session token could not be issued.") // Return 500 if signing fails.
+		return
+	}
+	logging.FromContext(r.Context()).Info("login succeeded", "audit", true, "user_id", user.ID, "remote_addr", r.RemoteAddr)
+
+	// Respond with the token, which must not be cached.
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(loginResponse{
+		AccessToken: token,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(math.Round(time.Until(expires).Seconds())),
+	})
+}
+
+// writeLocked writes the 429 problem for a locked account, telling the
+// client when to try again.
+func writeLocked(w http.ResponseWriter, r *http.Request, remaining time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(remaining.Seconds()))))
+	writeProblem(w, r, http.StatusTooManyRequests, "Too many failed attempts; try again later.")
+}
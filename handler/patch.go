@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"Curd/model"
+	"Curd/validation"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+)
+
+// Media types accepted by PATCH requests.
+const (
+	mediaTypeMergePatch = "application/merge-patch+json" // RFC 7396
+	mediaTypeJSONPatch  = "application/json-patch+json"  // RFC 6902
+)
+
+// errUnsupportedPatch is returned for a PATCH body in any other media type.
+var errUnsupportedPatch = errors.New("unsupported patch media type")
+
+// applyPatch applies a JSON Merge Patch or JSON Patch document, selected by
+// contentType, to the JSON form of user and decodes the result.
+// Patches that introduce fields the model does not have are rejected.
+func applyPatch(user model.User, contentType string, patch []byte) (model.User, error) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return model.User{}, errUnsupportedPatch
+	}
+
+	original, err := json.Marshal(user)
+	if err != nil {
+		return model.User{}, err
+	}
+
+	// Apply the patch to the stored document.
+	var patched []byte
+	switch mediaType {
+	case mediaTypeMergePatch:
+		patched, err = jsonpatch.MergePatch(original, patch)
+	case mediaTypeJSONPatch:
+		var ops jsonpatch.Patch
+		if ops, err = jsonpatch.DecodePatch(patch); err == nil {
+			patched, err = ops.Apply(original)
+		}
+	default:
+		return model.User{}, errUnsupportedPatch
+	}
+	if err != nil {
+		return model.User{}, fmt.Errorf("apply patch: %w", err)
+	}
+
+	// Decode the result strictly so unknown fields are an error, not silently dropped.
+	var result model.User
+	if err := strictDecode(bytes.NewReader(patched), &result); err != nil {
+		return model.User{}, err
+	}
+	return result, nil
+}
+
+// validatePatchedUser normalizes a patched user and checks that it is still a
+// valid record with the same ID, returning validation.Errors otherwise.
+func validatePatchedUser(original model.User, patched *model.User) error {
+	if patched.ID != original.ID {
+		return validation.Errors{{Field: "ID", Code: "immutable", Message: "cannot be changed"}}
+	}
+	return validation.Struct(patched)
+}
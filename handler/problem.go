@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"Curd/logging"
+	"Curd/validation"
+	"encoding/json"
+	"net/http"
+)
+
+// Problem is an RFC 7807 problem details object. Every error response from
+// the handlers in this package is rendered as one with the media type
+// application/problem+json.
+type Problem struct {
+	Type      string `json:"type"`                 // URI reference identifying the problem type.
+	Title     string `json:"title"`                // Short summary of the problem type.
+	Status    int    `json:"status"`               // HTTP status code.
+	Detail    string `json:"detail,omitempty"`     // Explanation specific to this occurrence.
+	Instance  string `json:"instance,omitempty"`   // URI reference of the request that failed.
+	RequestID string `json:"request_id,omitempty"` // Correlates the response with server logs.
+
+	// Errors lists every invalid field for validation problems (extension member).
+	Errors validation.Errors `json:"errors,omitempty"`
+
+	// Existing is the URI of the resource a conflict problem collided with (extension member).
+	Existing string `json:"existing,omitempty"`
+}
+
+// Problem types with a more specific meaning than their HTTP status.
+// Any other problem uses "about:blank", whose title is the status text.
+const (
+	problemValidation      = "/problems/validation-failed"
+	problemConflict        = "/problems/conflict"
+	problemVersion         = "/problems/version-mismatch"
+	problemTimeout         = "/problems/timeout"
+	problemUnauthenticated = "/problems/unauthenticated"
+	problemForbidden       = "/problems/forbidden"
+)
+
+// problemTitles are the titles of the specific problem types.
+var problemTitles = map[string]string{
+	problemValidation:      "Validation failed",
+	problemConflict:        "Resource already exists",
+	problemVersion:         "Resource was modified",
+	problemTimeout:         "Request timed out",
+	problemUnauthenticated: "Authentication required",
+	problemForbidden:       "Permission denied",
+}
+
+// writeProblem writes a generic problem for the status code with the given detail.
+func writeProblem(w http.ResponseWriter, r *http.Request, status int, detail string) {
+	renderProblem(w, r, Problem{Status: status, Detail: detail})
+}
+
+// renderProblem fills in the type, title, instance and request ID of a
+// problem and writes it as the response.
+func renderProblem(w http.ResponseWriter, r *http.Request, p Problem) {
+	if p.Type == "" {
+		p.Type = "about:blank"
+	}
+	if p.Title == "" {
+		p.Title = problemTitles[p.Type]
+	}
+	if p.Title == "" {
+		p.Title = http.StatusText(p.Status)
+	}
+	p.Instance = r.URL.RequestURI()
+	p.RequestID = requestID(w, r)
+
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
+
+// requestID returns the ID of the request assigned by logging.Middleware.
+// Without the middleware it is taken from the X-Request-ID request header or
+// generated, and echoed in the response header.
+func requestID(w http.ResponseWriter, r *http.Request) string {
+	if id := logging.RequestID(r.Context()); id != "" {
+		return id
+	}
+	if id := w.Header().Get(logging.RequestIDHeader); id != "" {
+		return id
+	}
+	id := r.Header.Get(logging.RequestIDHeader)
+	if id == "" {
+		id = logging.NewRequestID()
+	}
+	w.Header().Set(logging.RequestIDHeader, id)
+	return id
+}
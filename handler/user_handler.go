@@ -1,40 +1,85 @@
 package handler
 
 import (
-	"Curd/firebase"
+	"Curd/auth"
+	"Curd/authz"
+	"Curd/logging"
 	"Curd/model"
 	"Curd/notification"
 	"Curd/store"
+	"Curd/validation"
+	"context"
 	"encoding/json"
-	"log"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
-
-	"firebase.google.com/go/messaging"
+	"time"
 )
 
 // UserHandler handles HTTP requests related to user operations.
 type UserHandler struct {
 	Store store.UserStoreInterface // Interface for user data storage operations.
+
+	// Notifier announces user lifecycle events; nil disables notifications.
+	// Leave it nil when the store publishes events itself through an outbox
+	// (PostgresUserStore), otherwise every event is delivered twice.
+	Notifier notification.Notifier
+
+	// RequestTimeout bounds how long a single request may spend in the store.
+	// Zero means requests are only cancelled when the client disconnects.
+	RequestTimeout time.Duration
+
+	// Policy decides which operations each authenticated caller may perform,
+	// checked before any store call. Nil allows everything, which is only
+	// appropriate when authentication is disabled.
+	Policy *authz.Policy
+
+	// Lockout refuses password changes for an account after repeated wrong
+	// current passwords. It is shared with the LoginHandler, so guesses made
+	// through either endpoint count together. Nil disables the lockout.
+	Lockout *auth.Lockout
+
+	// Verification emails a link confirming the address of new users and of
+	// users who change their email. Nil disables email verification.
+	Verification *EmailVerification
 }
 
 // ServeHTTP routes incoming HTTP requests to the appropriate handler function.
 func (h *UserHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Apply the server-configured deadline to the request context, which every
+	// store call inherits.
+	if h.RequestTimeout > 0 {
+		ctx, cancel := context.WithTimeout(r.Context(), h.RequestTimeout)
+		defer cancel()
+		r = r.WithContext(ctx)
+	}
+
 	// Route requests based on HTTP method and URL path.
 	switch {
+	case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/password"):
+		h.ChangePassword(w, r) // Handle changing a user's password.
+	case r.Method == http.MethodGet && r.URL.Path == "/users/verify":
+		h.VerifyEmail(w, r) // Handle a followed verification link.
+	case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/verify"):
+		h.ResendVerification(w, r) // Handle sending a fresh verification link.
 	case r.Method == http.MethodPost && r.URL.Path == "/users":
 		h.CreateUser(w, r) // Handle user creation.
 	case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/users/"):
 		h.GetUser(w, r) // Handle fetching a single user by ID.
 	case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/users"):
-		h.GetAllUser(w, r) // Handle fetching all users.
+		h.GetAllUser(w, r) // Handle fetching a page of users.
 	case r.Method == http.MethodPut && strings.HasPrefix(r.URL.Path, "/users/"):
 		h.UpdateUser(w, r) // Handle updating a user by ID.
+	case r.Method == http.MethodPatch && strings.HasPrefix(r.URL.Path, "/users/"):
+		h.PatchUser(w, r) // Handle partially updating a user by ID.
 	case r.Method == http.MethodDelete && strings.HasPrefix(r.URL.Path, "/users/"):
 		h.DeleteUser(w, r) // Handle deleting a user by ID.
 	default:
-		http.NotFound(w, r) // Return 404 for unsupported routes.
+		writeProblem(w, r, http.StatusNotFound, "No route for "+r.Method+" "+r.URL.Path) // Return 404 for unsupported routes.
 	}
 }
 
@@ -47,67 +92,77 @@ func (h *UserHandler) extractID(r *http.Request) (int, error) {
 	return strconv.Atoi(parts[2]) // Convert ID to integer.
 }
 
+// notify delivers an event to the configured notifier, logging any failure.
+// Notification problems never fail the request that triggered them.
+func (h *UserHandler) notify(ctx context.Context, event notification.Event) {
+	if h.Notifier == nil {
+		return
+	}
+	if err := h.Notifier.Notify(ctx, event); err != nil {
+		logging.FromContext(ctx).Error("notification failed", "event", event.Type, "error", err) // Log notification failure.
+	}
+}
+
 // CreateUser handles the creation of a new user.
 func (h *UserHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
-	log.Printf("CreateUser Request: %s %s\n", r.Method, r.URL.Path)
+	// Check the caller may create users.
+	if !authorize(w, r, h.Policy, authz.ResourceUsers, authz.ActionCreate, 0) {
+		return // Return 403 if not allowed.
+	}
 
-	// Decode the request body into a User object.
-	var user model.User
-	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
-		http.Error(w, "Invalid input", http.StatusBadRequest) // Return 400 for invalid input.
+	// Decode, normalize and validate the request body into a User object.
+	user, err := decodeUser(r)
+	if err != nil {
+		writeDecodeError(w, r, err) // Return 422 listing every invalid field, 400 for malformed JSON.
 		return
 	}
 
 	// Create the user in the data store.
-	created, err := h.Store.CreateUser(user)
+	created, err := h.Store.CreateUser(r.Context(), user)
 	if err != nil {
-		http.Error(w, "Failed to create user", http.StatusInternalServerError) // Return 500 for server error.
+		writeStoreError(w, r, err) // Map the store error onto an HTTP status.
 		return
 	}
 
-	// Send FCM Notification for the new user.
-	message := &messaging.Message{
-		Notification: &messaging.Notification{
-			Title: "New User Created",
-			Body:  "User " + created.Name + " has been successfully created.",
-		},
-		Topic: "user-updates",
-	}
-	_, err = firebase.FCMClient.Send(r.Context(), message)
-	if err != nil {
-		log.Printf("Failed to send FCM notification: %v", err) // Log FCM notification failure.
-	}
+	// Announce the new user (push notification, welcome email, ...).
+	h.notify(r.Context(), notification.Event{Type: notification.EventUserCreated, User: created})
 
-	// Send Email Notification to the new user.
-	err = notification.SendEmail(
-		created.Email,
-		"Welcome to Our Service",
-		"Hello "+created.Name+", welcome to our platform!",
-	)
-	if err != nil {
-		log.Printf("Failed to send email notification: %v", err) // Log email notification failure.
+	// Ask the user to confirm their email.
+	if h.Verification != nil {
+		h.Verification.send(r.Context(), created)
 	}
 
-	// Respond with the created user object and HTTP 201 status.
+	// Respond with the created user object, its ETag and HTTP 201 status.
+	w.Header().Set("ETag", etag(created))
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(created)
 }
 
 // GetUser handles fetching a single user by ID.
 func (h *UserHandler) GetUser(w http.ResponseWriter, r *http.Request) {
-	log.Printf("GetUser Request: %s %s\n", r.Method, r.URL.Path)
-
 	// Extract the user ID from the URL path.
 	id, err := h.extractID(r)
 	if err != nil {
-		http.Error(w, "Invalid user ID", http.StatusBadRequest) // Return 400 for invalid ID.
+		writeProblem(w, r, http.StatusBadRequest, "Invalid user ID") // Return 400 for invalid ID.
 		return
 	}
 
+	// Check the caller may read this user.
+	if !authorize(w, r, h.Policy, authz.ResourceUsers, authz.ActionRead, id) {
+		return // Return 403 if not allowed.
+	}
+
 	// Fetch the user from the data store.
-	user, err := h.Store.GetUser(id)
+	user, err := h.Store.GetUser(r.Context(), id)
 	if err != nil {
-		http.Error(w, "User not found", http.StatusNotFound) // Return 404 if user is not found.
+		writeStoreError(w, r, err) // Return 404 if user is not found, 503 if the store is down.
+		return
+	}
+
+	// Let clients revalidate cached copies with If-None-Match.
+	w.Header().Set("ETag", etag(user))
+	if inm := r.Header.Get("If-None-Match"); inm != "" && etagMatchesWeak(inm, etag(user)) {
+		w.WriteHeader(http.StatusNotModified) // Return 304 if the client's copy is current.
 		return
 	}
 
@@ -115,67 +170,417 @@ func (h *UserHandler) GetUser(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(user)
 }
 
-// GetAllUser handles fetching all users.
+// userListResponse is the envelope returned by GetAllUser.
+type userListResponse struct {
+	Users      []model.User `json:"users"`                 // Users on the current page.
+	NextCursor string       `json:"next_cursor,omitempty"` // Cursor for the next page, omitted on the last page.
+}
+
+// GetAllUser handles fetching a page of users.
+// Supported query parameters: limit, cursor, sort (id, name, email; prefix
+// with "-" for descending), name_prefix, email_domain, min_id and max_id.
 func (h *UserHandler) GetAllUser(w http.ResponseWriter, r *http.Request) {
-	log.Printf("GetAllUser Request: %s %s\n", r.Method, r.URL.Path)
+	// Check the caller may list users.
+	if !authorize(w, r, h.Policy, authz.ResourceUsers, authz.ActionList, 0) {
+		return // Return 403 if not allowed.
+	}
 
-	// Fetch all users from the data store.
-	users, err := h.Store.GetAllUser()
+	// Parse pagination, sorting and filtering options from the query string.
+	opts, err := parseListOptions(r.URL.Query())
 	if err != nil {
-		http.Error(w, "No users found", http.StatusNotFound) // Return 404 if no users are found.
+		writeProblem(w, r, http.StatusBadRequest, err.Error()) // Return 400 for malformed query parameters.
 		return
 	}
 
-	// Respond with the list of users.
-	json.NewEncoder(w).Encode(users)
+	// Fetch the requested page of users from the data store.
+	result, err := h.Store.ListUsers(r.Context(), opts)
+	if err != nil {
+		writeStoreError(w, r, err) // Return 400 for a stale or forged cursor, 503 if the store is down.
+		return
+	}
+
+	// Respond with the page of users and the cursor for the next one.
+	resp := userListResponse{Users: result.Users, NextCursor: result.NextCursor}
+	if resp.Users == nil {
+		resp.Users = []model.User{} // Encode an empty page as [] rather than null.
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// parseListOptions converts list query parameters into store.ListOptions.
+func parseListOptions(q url.Values) (store.ListOptions, error) {
+	opts := store.ListOptions{
+		Cursor:      q.Get("cursor"),
+		NamePrefix:  q.Get("name_prefix"),
+		EmailDomain: q.Get("email_domain"),
+	}
+
+	// Parse the integer parameters, rejecting anything that is not a non-negative number.
+	for name, dst := range map[string]*int{"limit": &opts.Limit, "min_id": &opts.MinID, "max_id": &opts.MaxID} {
+		if v := q.Get(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return store.ListOptions{}, fmt.Errorf("invalid %s: %q", name, v)
+			}
+			*dst = n
+		}
+	}
+
+	// A leading "-" on the sort field requests descending order.
+	if sort := q.Get("sort"); sort != "" {
+		opts.Desc = strings.HasPrefix(sort, "-")
+		opts.Sort = store.SortField(strings.TrimPrefix(sort, "-"))
+		if !opts.Sort.Valid() {
+			return store.ListOptions{}, fmt.Errorf("invalid sort: %q", sort)
+		}
+	}
+	return opts, nil
 }
 
 // UpdateUser handles updating a user by ID.
 func (h *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
-	log.Printf("UpdateUser Request: %s %s\n", r.Method, r.URL.Path)
+	// Extract the user ID from the URL path.
+	id, err := h.extractID(r)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, "Invalid user ID") // Return 400 for invalid ID.
+		return
+	}
+
+	// Check the caller may update this user.
+	if !authorize(w, r, h.Policy, authz.ResourceUsers, authz.ActionUpdate, id) {
+		return // Return 403 if not allowed.
+	}
+
+	// Decode, normalize and validate the request body into a User object.
+	user, err := decodeUser(r)
+	if err != nil {
+		writeDecodeError(w, r, err) // Return 422 listing every invalid field, 400 for malformed JSON.
+		return
+	}
+
+	// Honor If-Match so concurrent editors cannot overwrite each other.
+	ifVersion, ok := h.precondition(w, r, id)
+	if !ok {
+		return
+	}
 
+	// Update the user in the data store. The store tells whether the email
+	// changed, comparing it with the user the write replaced.
+	updated, emailChanged, err := h.Store.UpdateUser(r.Context(), id, user, ifVersion)
+	if err != nil {
+		writeStoreError(w, r, err) // Return 404 if user is not found, 412 if it changed meanwhile.
+		return
+	}
+
+	// Announce the change, and ask the user to confirm a new email.
+	h.notify(r.Context(), notification.Event{Type: notification.EventUserUpdated, User: updated})
+	if h.Verification != nil && emailChanged {
+		h.Verification.send(r.Context(), updated)
+	}
+
+	// Respond with the updated user object and its new ETag.
+	w.Header().Set("ETag", etag(updated))
+	json.NewEncoder(w).Encode(updated)
+}
+
+// precondition evaluates the request's If-Match header for the user with the
+// given ID, writing an error response and returning false if it fails.
+// It returns the version the store must still hold for the write to proceed.
+func (h *UserHandler) precondition(w http.ResponseWriter, r *http.Request, id int) (int, bool) {
+	if r.Header.Get("If-Match") == "" {
+		return 0, true // Unconditional write.
+	}
+	current, err := h.Store.GetUser(r.Context(), id)
+	if err != nil {
+		writeStoreError(w, r, err) // Return 404 if user is not found, 503 if the store is down.
+		return 0, false
+	}
+	ifVersion, ok := expectedVersion(r, current)
+	if !ok {
+		writeVersionMismatch(w, r) // Return 412 for a stale ETag.
+	}
+	return ifVersion, ok
+}
+
+// PatchUser handles partially updating a user by ID.
+// The body is either a JSON Merge Patch (application/merge-patch+json) or a
+// JSON Patch (application/json-patch+json) applied to the stored user.
+func (h *UserHandler) PatchUser(w http.ResponseWriter, r *http.Request) {
 	// Extract the user ID from the URL path.
 	id, err := h.extractID(r)
 	if err != nil {
-		http.Error(w, "Invalid user ID", http.StatusBadRequest) // Return 400 for invalid ID.
+		writeProblem(w, r, http.StatusBadRequest, "Invalid user ID") // Return 400 for invalid ID.
 		return
 	}
 
-	// Decode the request body into a User object.
-	var user model.User
-	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
-		http.Error(w, "Invalid input", http.StatusBadRequest) // Return 400 for invalid input.
+	// Check the caller may update this user.
+	if !authorize(w, r, h.Policy, authz.ResourceUsers, authz.ActionUpdate, id) {
+		return // Return 403 if not allowed.
+	}
+
+	// Read the patch document.
+	patch, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, "Invalid input") // Return 400 for an unreadable body.
 		return
 	}
 
-	// Update the user in the data store.
-	updated, err := h.Store.UpdateUser(id, user)
+	// Fetch the current user from the data store.
+	current, err := h.Store.GetUser(r.Context(), id)
 	if err != nil {
-		http.Error(w, "User not found", http.StatusNotFound) // Return 404 if user is not found.
+		writeStoreError(w, r, err) // Return 404 if user is not found, 503 if the store is down.
+		return
+	}
+
+	// Honor If-Match. Even without it, the patch was computed against this
+	// version, so the save must fail if another write lands in between.
+	ifVersion, ok := expectedVersion(r, current)
+	if !ok {
+		writeVersionMismatch(w, r) // Return 412 for a stale ETag.
+		return
+	}
+	if ifVersion == 0 {
+		ifVersion = current.Version
+	}
+
+	// Apply the patch and check the result is still a valid user.
+	patched, err := applyPatch(current, r.Header.Get("Content-Type"), patch)
+	if errors.Is(err, errUnsupportedPatch) {
+		w.Header().Set("Accept-Patch", mediaTypeMergePatch+", "+mediaTypeJSONPatch)
+		writeProblem(w, r, http.StatusUnsupportedMediaType, "Unsupported patch format") // Return 415 for other media types.
+		return
+	}
+	var fieldErrs validation.Errors
+	if errors.As(err, &fieldErrs) {
+		writeDecodeError(w, r, err) // Return 422 if the patch adds unknown fields.
+		return
+	}
+	if err != nil {
+		writeProblem(w, r, http.StatusUnprocessableEntity, err.Error()) // Return 422 for a patch that cannot be applied.
+		return
+	}
+	if err := validatePatchedUser(current, &patched); err != nil {
+		writeDecodeError(w, r, err) // Return 422 listing every invalid field.
 		return
 	}
 
-	// Respond with the updated user object.
+	// Save the patched user in the data store.
+	updated, emailChanged, err := h.Store.UpdateUser(r.Context(), id, patched, ifVersion)
+	if err != nil {
+		writeStoreError(w, r, err) // Return 404 if user is not found, 412 if it changed meanwhile.
+		return
+	}
+
+	// Announce the change, and ask the user to confirm a new email.
+	h.notify(r.Context(), notification.Event{Type: notification.EventUserUpdated, User: updated})
+	if h.Verification != nil && emailChanged {
+		h.Verification.send(r.Context(), updated)
+	}
+
+	// Respond with the updated user object and its new ETag.
+	w.Header().Set("ETag", etag(updated))
 	json.NewEncoder(w).Encode(updated)
 }
 
 // DeleteUser handles deleting a user by ID.
 func (h *UserHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
-	log.Printf("DeleteUser Request: %s %s\n", r.Method, r.URL.Path)
-
 	// Extract the user ID from the URL path.
 	id, err := h.extractID(r)
 	if err != nil {
-		http.Error(w, "Invalid user ID", http.StatusBadRequest) // Return 400 for invalid ID.
+		writeProblem(w, r, http.StatusBadRequest, "Invalid user ID") // Return 400 for invalid ID.
+		return
+	}
+
+	// Check the caller may delete this user.
+	if !authorize(w, r, h.Policy, authz.ResourceUsers, authz.ActionDelete, id) {
+		return // Return 403 if not allowed.
+	}
+
+	// Load the user first so the deletion notice can say who was removed
+	// and any If-Match precondition can be checked.
+	var deleted model.User
+	if h.Notifier != nil || r.Header.Get("If-Match") != "" {
+		if deleted, err = h.Store.GetUser(r.Context(), id); err != nil {
+			writeStoreError(w, r, err) // Return 404 if user is not found, 503 if the store is down.
+			return
+		}
+	}
+	ifVersion, ok := expectedVersion(r, deleted)
+	if !ok {
+		writeVersionMismatch(w, r) // Return 412 for a stale ETag.
 		return
 	}
 
 	// Delete the user from the data store.
-	if err := h.Store.DeleteUser(id); err != nil {
-		http.Error(w, "User not found", http.StatusNotFound) // Return 404 if user is not found.
+	if err := h.Store.DeleteUser(r.Context(), id, ifVersion); err != nil {
+		writeStoreError(w, r, err) // Return 404 if user is not found, 412 if it changed meanwhile.
 		return
 	}
 
+	// Announce the deletion.
+	if h.Notifier != nil {
+		h.notify(r.Context(), notification.Event{Type: notification.EventUserDeleted, User: deleted})
+	}
+
 	// Respond with HTTP 204 No Content status.
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// passwordRequest is the body of a password change. Passwords are neither
+// trimmed nor otherwise normalized.
+type passwordRequest struct {
+	OldPassword string `json:"old_password"`                                   // Required once the user has a password.
+	NewPassword string `json:"new_password" validate:"required,min=8,max=128"` // The password to set.
+}
+
+// ChangePassword handles setting the password of the user at
+// /users/{id}/password. Once a password is set, changing it requires the
+// current one, and wrong guesses count towards the lockout.
+func (h *UserHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	// Extract the user ID from the URL path.
+	id, err := h.extractID(r)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, "Invalid user ID") // Return 400 for invalid ID.
+		return
+	}
+
+	// Check the caller may update this user.
+	if !authorize(w, r, h.Policy, authz.ResourceUsers, authz.ActionUpdate, id) {
+		return // Return 403 if not allowed.
+	}
+
+	// Decode and validate the request body.
+	var req passwordRequest
+	err = strictDecode(r.Body, &req)
+	if err == nil {
+		err = validation.Struct(&req)
+	}
+	if err != nil {
+		writeDecodeError(w, r, err) // Return 422 listing every invalid field, 400 for malformed JSON.
+		return
+	}
+
+	// Fetch the user, whose email identifies the account to the lockout.
+	user, err := h.Store.GetUser(r.Context(), id)
+	if err != nil {
+		writeStoreError(w, r, err) // Return 404 if user is not found, 503 if the store is down.
+		return
+	}
+	account := strings.ToLower(user.Email)
+	if h.Lockout != nil {
+		if remaining, locked := h.Lockout.Locked(account); locked {
+			writeLocked(w, r, remaining) // Return 429 while the account is locked.
+			return
+		}
+	}
+
+	// Require the current password, if there is one.
+	if user.PasswordHash != "" {
+		ok, err := auth.VerifyPassword(user.PasswordHash, req.OldPassword)
+		if err != nil {
+			logging.FromContext(r.Context()).Error("stored password hash is invalid", "user_id", user.ID, "error", err)
+			writeProblem(w, r, http.StatusInternalServerError, "The password could not be checked.") // Return 500 for a corrupt hash.
+			return
+		}
+		if !ok {
+			if h.Lockout != nil {
+				h.Lockout.Fail(account)
+			}
+			logging.FromContext(r.Context()).Warn("password change rejected", "audit", true, "user_id", user.ID)
+			renderProblem(w, r, Problem{Type: problemForbidden, Status: http.StatusForbidden, Detail: "The current password is incorrect."})
+			return
+		}
+	}
+
+	// Store the new hash.
+	if err := h.Store.SetPassword(r.Context(), id, auth.HashPassword(req.NewPassword)); err != nil {
+		writeStoreError(w, r, err) // Return 404 if user is not found, 503 if the store is down.
+		return
+	}
+	if h.Lockout != nil {
+		h.Lockout.Reset(account)
+	}
+	logging.FromContext(r.Context()).Info("password changed", "audit", true, "user_id", user.ID)
+
+	// Respond with HTTP 204 No Content status.
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// VerifyEmail handles a followed verification link, /users/verify?token=...
+// It needs no credentials: the signed token proves the caller received the
+// email. Following a link again is harmless.
+func (h *UserHandler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	if h.Verification == nil {
+		writeProblem(w, r, http.StatusNotFound, "Email verification is not enabled") // Return 404 when verification is off.
+		return
+	}
+
+	// Check the token and find whose email it verifies.
+	id, email, err := h.Verification.Tokens.Verify(r.URL.Query().Get("token"))
+	if errors.Is(err, auth.ErrExpired) {
+		writeProblem(w, r, http.StatusBadRequest, "The verification link has expired; request a new one.") // Return 400 for an expired link.
+		return
+	}
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, "The verification link is invalid.") // Return 400 for a forged or mangled link.
+		return
+	}
+
+	// The link only verifies the address it was sent to.
+	user, err := h.Store.GetUser(r.Context(), id)
+	if err != nil {
+		writeStoreError(w, r, err) // Return 404 if user is not found, 503 if the store is down.
+		return
+	}
+	if !strings.EqualFold(user.Email, email) {
+		writeProblem(w, r, http.StatusBadRequest, "The verification link is for an email the user no longer has.") // Return 400 for a stale link.
+		return
+	}
+
+	// Mark the email as verified, unless it already is.
+	if !user.EmailVerified {
+		if user, err = h.Store.VerifyEmail(r.Context(), id, email); err != nil {
+			writeStoreError(w, r, err) // Return 404 if the user or email changed meanwhile, 503 if the store is down.
+			return
+		}
+		logging.FromContext(r.Context()).Info("email verified", "audit", true, "user_id", user.ID)
+	}
+
+	// Respond with HTTP 204 No Content status.
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ResendVerification handles sending a fresh verification link to the user
+// at /users/{id}/verify, e.g. after the first one expired.
+func (h *UserHandler) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	// Extract the user ID from the URL path.
+	id, err := h.extractID(r)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, "Invalid user ID") // Return 400 for invalid ID.
+		return
+	}
+
+	// Check the caller may update this user.
+	if !authorize(w, r, h.Policy, authz.ResourceUsers, authz.ActionUpdate, id) {
+		return // Return 403 if not allowed.
+	}
+	if h.Verification == nil {
+		writeProblem(w, r, http.StatusNotFound, "Email verification is not enabled") // Return 404 when verification is off.
+		return
+	}
+
+	// Fetch the user from the data store.
+	user, err := h.Store.GetUser(r.Context(), id)
+	if err != nil {
+		writeStoreError(w, r, err) // Return 404 if user is not found, 503 if the store is down.
+		return
+	}
+	if user.EmailVerified {
+		writeProblem(w, r, http.StatusConflict, "The email is already verified.") // Return 409 if there is nothing to verify.
+		return
+	}
+
+	// Send the link and respond with HTTP 202 Accepted status.
+	h.Verification.send(r.Context(), user)
+	w.WriteHeader(http.StatusAccepted)
+}
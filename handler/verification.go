@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"Curd/auth"
+	"Curd/logging"
+	"Curd/model"
+	"Curd/notification"
+	"context"
+	"net/url"
+	"time"
+)
+
+// verificationSendTimeout bounds how long a verification email may take to
+// send once the request that triggered it has returned.
+const verificationSendTimeout = 30 * time.Second
+
+// VerificationTarget names the notification target that sends verification emails.
+const VerificationTarget = "verification"
+
+// EmailVerification emails users a signed link confirming their address,
+// when they are created and whenever their email changes. Following the
+// link calls GET /users/verify, which marks the email as verified.
+type EmailVerification struct {
+	Tokens *auth.VerificationTokens // Signs and checks the tokens in the links.
+	URL    string                   // Public address of GET /users/verify; the token is added as ?token=.
+	Mailer notification.Mailer      // Sends the verification email.
+
+	// Notifier delivers the verification events to Notify, e.g.
+	// Queue.Target(VerificationTarget) so sends are retried, measured and
+	// dead-lettered like notifications. Nil calls Notify directly, once.
+	Notifier notification.Notifier
+}
+
+// link returns the verification link for the user's current email.
+func (v *EmailVerification) link(user model.User) (string, error) {
+	token, err := v.Tokens.Issue(user)
+	if err != nil {
+		return "", err
+	}
+	u, err := url.Parse(v.URL)
+	if err != nil {
+		return "", err
+	}
+	q := u.Query()
+	q.Set("token", token)
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// Notify emails the user of an EventEmailVerification a fresh verification
+// link, implementing notification.Notifier so it can be a queue target. The
+// link is only created here, so its token is never kept in dead letters.
+// Other events are ignored.
+func (v *EmailVerification) Notify(ctx context.Context, event notification.Event) error {
+	if event.Type != notification.EventEmailVerification {
+		return nil // Not a verification; other targets announce it.
+	}
+	link, err := v.link(event.User)
+	if err != nil {
+		return err
+	}
+	return v.Mailer.Send(ctx, notification.TemplateVerifyEmail, notification.TemplateData{User: event.User, Link: link})
+}
+
+// send emails the user a fresh verification link in the background, so a
+// slow mail service does not delay the response. Like notifications,
+// failures are logged and never fail the request.
+func (v *EmailVerification) send(ctx context.Context, user model.User) {
+	logger := logging.FromContext(ctx)
+	event := notification.Event{Type: notification.EventEmailVerification, User: user}
+	if v.Notifier != nil {
+		if err := v.Notifier.Notify(ctx, event); err != nil {
+			logger.Error("queueing verification email failed", "user_id", user.ID, "error", err)
+		}
+		return
+	}
+
+	// Keep the request's logger and trace, but not its deadline.
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), verificationSendTimeout)
+	go func() {
+		defer cancel()
+		if err := v.Notify(ctx, event); err != nil {
+			logger.Error("sending verification email failed", "user_id", user.ID, "error", err)
+			return
+		}
+		logger.Info("verification email sent", "user_id", user.ID)
+	}()
+}
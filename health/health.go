@@ -0,0 +1,133 @@
+// Package health probes the service's dependencies for readiness checks.
+package health
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Component statuses.
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+// DefaultTimeout bounds each probe when Registry.Timeout is zero.
+const DefaultTimeout = 2 * time.Second
+
+// Checker is implemented by dependencies that can report whether they are
+// usable, such as stores (by pinging the database) and notifiers (by a
+// dry-run send). Check returns nil when the dependency is healthy.
+type Checker interface {
+	Check(ctx context.Context) error
+}
+
+// CheckerFunc adapts a function to the Checker interface.
+type CheckerFunc func(ctx context.Context) error
+
+// Check calls f.
+func (f CheckerFunc) Check(ctx context.Context) error {
+	return f(ctx)
+}
+
+// ComponentStatus is the result of the latest probe of one component.
+type ComponentStatus struct {
+	Name        string     `json:"name"`
+	Status      string     `json:"status"`                  // StatusUp or StatusDown.
+	LatencyMS   float64    `json:"latency_ms"`              // Duration of the latest probe.
+	LastError   string     `json:"last_error,omitempty"`    // Most recent failure, kept after recovery.
+	LastErrorAt *time.Time `json:"last_error_at,omitempty"` // When LastError happened.
+}
+
+// Report is the overall readiness and the status of every component.
+type Report struct {
+	Status     string            `json:"status"` // StatusUp only if every component is up.
+	Components []ComponentStatus `json:"components"`
+}
+
+// Registry holds the checkers probed for readiness and remembers the last
+// error of each one.
+type Registry struct {
+	Timeout time.Duration // Deadline for each probe.
+
+	mu         sync.Mutex
+	checkers   map[string]Checker
+	lastErrors map[string]lastError
+}
+
+// lastError is the most recent failure of a component.
+type lastError struct {
+	message string
+	at      time.Time
+}
+
+// NewRegistry returns an empty registry whose probes time out after timeout.
+func NewRegistry(timeout time.Duration) *Registry {
+	return &Registry{
+		Timeout:    timeout,
+		checkers:   make(map[string]Checker),
+		lastErrors: make(map[string]lastError),
+	}
+}
+
+// Register adds a component to probe, replacing any checker with the same name.
+func (r *Registry) Register(name string, c Checker) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checkers[name] = c
+}
+
+// Check probes every component concurrently and reports their status,
+// sorted by name.
+func (r *Registry) Check(ctx context.Context) Report {
+	timeout := r.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	r.mu.Lock()
+	checkers := make(map[string]Checker, len(r.checkers))
+	for name, c := range r.checkers {
+		checkers[name] = c
+	}
+	r.mu.Unlock()
+
+	report := Report{Status: StatusUp, Components: make([]ComponentStatus, 0, len(checkers))}
+	results := make(chan ComponentStatus, len(checkers))
+	for name, c := range checkers {
+		go func() { results <- r.probe(ctx, name, c, timeout) }()
+	}
+	for range checkers {
+		status := <-results
+		if status.Status != StatusUp {
+			report.Status = StatusDown
+		}
+		report.Components = append(report.Components, status)
+	}
+	sort.Slice(report.Components, func(i, j int) bool { return report.Components[i].Name < report.Components[j].Name })
+	return report
+}
+
+// probe runs one checker under the timeout and records its outcome.
+func (r *Registry) probe(ctx context.Context, name string, c Checker, timeout time.Duration) ComponentStatus {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := c.Check(ctx)
+	status := ComponentStatus{Name: name, Status: StatusUp, LatencyMS: float64(time.Since(start).Microseconds()) / 1000}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err != nil {
+		status.Status = StatusDown
+		r.lastErrors[name] = lastError{message: err.Error(), at: start.UTC()}
+	}
+	if last, ok := r.lastErrors[name]; ok {
+		status.LastError = last.message
+		status.LastErrorAt = &last.at
+	}
+	return status
+}
@@ -0,0 +1,95 @@
+// Package lifecycle runs the HTTP server and shuts the service down in order.
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+)
+
+// DefaultShutdownTimeout bounds the whole shutdown when Manager.ShutdownTimeout is zero.
+const DefaultShutdownTimeout = 30 * time.Second
+
+// hook is a named shutdown step.
+type hook struct {
+	name string
+	stop func(ctx context.Context) error
+}
+
+// Manager serves HTTP until its context is cancelled, then stops accepting
+// connections, waits for in-flight requests and runs the shutdown hooks in
+// reverse order of registration, all within ShutdownTimeout.
+type Manager struct {
+	Server          *http.Server
+	ShutdownTimeout time.Duration // Deadline for draining requests and running hooks.
+
+	hooks []hook
+}
+
+// OnShutdown registers a step to run after the HTTP server has drained.
+// Like deferred calls, steps run one at a time in reverse registration order,
+// so registering each component as soon as it is created stops its users
+// before it (e.g. the notification queue before the database pool).
+// Every step runs even if an earlier one fails.
+func (m *Manager) OnShutdown(name string, stop func(ctx context.Context) error) {
+	m.hooks = append(m.hooks, hook{name: name, stop: stop})
+}
+
+// Run listens on Server.Addr and serves until ctx is cancelled, then shuts down.
+func (m *Manager) Run(ctx context.Context) error {
+	ln, err := net.Listen("tcp", m.Server.Addr)
+	if err != nil {
+		return errors.Join(err, m.shutdown()) // Still release what was started before serving.
+	}
+	return m.Serve(ctx, ln)
+}
+
+// Serve serves on ln until ctx is cancelled or the server fails, then shuts
+// down. It returns the serve error, if any, joined with every shutdown error.
+func (m *Manager) Serve(ctx context.Context, ln net.Listener) error {
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- m.Server.Serve(ln) }()
+	slog.Info("server started", "addr", ln.Addr().String())
+
+	var errs []error
+	select {
+	case <-ctx.Done():
+		slog.Info("shutting down", "cause", context.Cause(ctx))
+	case err := <-serveErr:
+		errs = append(errs, err) // The server died on its own; still release everything else.
+	}
+	return errors.Join(append(errs, m.shutdown())...)
+}
+
+// shutdown drains the HTTP server and runs the hooks under one deadline.
+func (m *Manager) shutdown() error {
+	timeout := m.ShutdownTimeout
+	if timeout <= 0 {
+		timeout = DefaultShutdownTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var errs []error
+	start := time.Now()
+	if err := m.Server.Shutdown(ctx); err != nil {
+		errs = append(errs, err)
+		m.Server.Close() // Cut the connections that did not finish in time.
+	}
+	slog.Info("http server stopped", "duration", time.Since(start))
+
+	for i := len(m.hooks) - 1; i >= 0; i-- {
+		h := m.hooks[i]
+		start := time.Now()
+		if err := h.stop(ctx); err != nil {
+			slog.Error("shutdown step failed", "component", h.name, "error", err)
+			errs = append(errs, err)
+			continue
+		}
+		slog.Info("shutdown step finished", "component", h.name, "duration", time.Since(start))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,77 @@
+// Package logging builds the service's structured logger and scopes it to
+// requests, so every line logged while handling a request carries its ID.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// Options configures New.
+type Options struct {
+	Level     string // "debug", "info", "warn" or "error"; empty means "info".
+	Format    string // "json" or "text"; empty means "json".
+	RedactPII bool   // Mask personal data such as emails; see Redact.
+}
+
+// New returns a logger writing to w. Lines are JSON objects unless Format is "text".
+func New(w io.Writer, opts Options) (*slog.Logger, error) {
+	var level slog.Level
+	if opts.Level != "" {
+		if err := level.UnmarshalText([]byte(opts.Level)); err != nil {
+			return nil, fmt.Errorf("log level: %w", err)
+		}
+	}
+	handlerOpts := &slog.HandlerOptions{Level: level}
+
+	var h slog.Handler
+	switch strings.ToLower(opts.Format) {
+	case "", "json":
+		h = slog.NewJSONHandler(w, handlerOpts)
+	case "text":
+		h = slog.NewTextHandler(w, handlerOpts)
+	default:
+		return nil, fmt.Errorf("unknown log format %q", opts.Format)
+	}
+	if opts.RedactPII {
+		h = Redact(h)
+	}
+	return slog.New(h), nil
+}
+
+// contextKey is the type of the context keys defined by this package.
+type contextKey int
+
+const (
+	loggerKey contextKey = iota
+	requestIDKey
+)
+
+// WithLogger returns a copy of ctx carrying the logger.
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey, logger)
+}
+
+// FromContext returns the logger carried by ctx, or slog.Default() if none.
+// Handlers, stores and notifiers log through it so their lines carry the
+// request ID of the request they serve.
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// RequestID returns the request ID carried by ctx, or "" if none.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// contextWithRequestID returns a copy of ctx carrying the request ID.
+func contextWithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey, id)
+}
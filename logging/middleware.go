@@ -0,0 +1,81 @@
+package logging
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RequestIDHeader carries the request ID in requests and responses.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds client-supplied request IDs kept in logs.
+const maxRequestIDLength = 128
+
+// Middleware gives every request an ID, taken from the X-Request-ID header
+// or generated, and echoes it in the response. It puts the ID and a logger
+// scoped to the request (request_id, plus trace_id when the request is
+// traced) into the request context, and logs each request once it is served.
+func Middleware(base *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			id := r.Header.Get(RequestIDHeader)
+			if id == "" || len(id) > maxRequestIDLength {
+				id = NewRequestID()
+			}
+			w.Header().Set(RequestIDHeader, id)
+
+			logger := base.With("request_id", id)
+			if span := trace.SpanContextFromContext(r.Context()); span.IsValid() {
+				logger = logger.With("trace_id", span.TraceID().String())
+			}
+			ctx := WithLogger(r.Context(), logger)
+			ctx = contextWithRequestID(ctx, id)
+
+			rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+			r = r.WithContext(ctx)
+			next.ServeHTTP(rec, r)
+
+			level := slog.LevelInfo
+			if rec.status >= 500 {
+				level = slog.LevelError
+			}
+			logger.LogAttrs(ctx, level, "request",
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+				slog.String("route", r.Pattern),
+				slog.Int("status", rec.status),
+				slog.Duration("duration", time.Since(start)),
+			)
+		})
+	}
+}
+
+// NewRequestID returns a random request ID.
+func NewRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// statusRecorder captures the status code written by a handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+// WriteHeader records the status code before writing it.
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
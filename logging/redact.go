@@ -0,0 +1,87 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+)
+
+// redacted replaces sensitive values in log lines.
+const redacted = "[REDACTED]"
+
+// piiKeys are the attribute keys whose values are masked, at any depth.
+var piiKeys = map[string]bool{
+	"email":         true,
+	"name":          true,
+	"password":      true,
+	"token":         true,
+	"authorization": true,
+	"api_key":       true,
+}
+
+// redactHandler is a slog.Handler that masks personal data before passing
+// records to the handler it wraps.
+type redactHandler struct {
+	next slog.Handler
+}
+
+// Redact wraps h so the values of attributes named email, name, password,
+// token, authorization or api_key are masked, including inside groups and
+// values such as model.User that log themselves as groups. Emails keep
+// their domain ("***@example.com") to help troubleshooting.
+func Redact(h slog.Handler) slog.Handler {
+	return redactHandler{next: h}
+}
+
+// Enabled reports whether the wrapped handler handles records at level.
+func (h redactHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+// Handle masks the record's attributes and passes it on.
+func (h redactHandler) Handle(ctx context.Context, r slog.Record) error {
+	clean := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		clean.AddAttrs(redactAttr(a))
+		return true
+	})
+	return h.next.Handle(ctx, clean)
+}
+
+// WithAttrs masks attrs before adding them to the wrapped handler.
+func (h redactHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clean := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		clean[i] = redactAttr(a)
+	}
+	return redactHandler{next: h.next.WithAttrs(clean)}
+}
+
+// WithGroup opens a group on the wrapped handler.
+func (h redactHandler) WithGroup(name string) slog.Handler {
+	return redactHandler{next: h.next.WithGroup(name)}
+}
+
+// redactAttr masks a if its key is sensitive, recursing into groups.
+func redactAttr(a slog.Attr) slog.Attr {
+	a.Value = a.Value.Resolve()
+	if a.Value.Kind() == slog.KindGroup {
+		attrs := a.Value.Group()
+		clean := make([]slog.Attr, len(attrs))
+		for i, attr := range attrs {
+			clean[i] = redactAttr(attr)
+		}
+		return slog.Attr{Key: a.Key, Value: slog.GroupValue(clean...)}
+	}
+
+	key := strings.ToLower(a.Key)
+	if !piiKeys[key] {
+		return a
+	}
+	if key == "email" {
+		if at := strings.LastIndex(a.Value.String(), "@"); at >= 0 {
+			return slog.String(a.Key, "***"+a.Value.String()[at:])
+		}
+	}
+	return slog.String(a.Key, redacted)
+}
@@ -1,49 +1,295 @@
 package main
 
 import (
+	"Curd/auth"
+	"Curd/authz"
+	"Curd/config"
 	"Curd/firebase"
 	"Curd/handler"
+	"Curd/health"
+	"Curd/lifecycle"
+	"Curd/logging"
+	"Curd/metrics"
+	"Curd/notification"
 	"Curd/router"
 	"Curd/store"
-	"log"
+	"Curd/tracing"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
 func main() {
+	// Load the configuration from flags, environment variables and an optional file
+	// Secrets such as the DSN password and the SendGrid key are never hardcoded
+	cfg, args, err := config.Load(os.Args[1:], os.Getenv)
+	if errors.Is(err, flag.ErrHelp) {
+		fmt.Fprintf(os.Stderr, "Usage: %s [flags] [migrate up|down [n]|status | config]\n", os.Args[0])
+		config.Usage(os.Stderr)
+		return
+	}
+	if err != nil {
+		fatal("invalid configuration", err)
+	}
 
-	// Initialize Firebase with the credentials file path
-	// This sets up Firebase services using the provided service account JSON file
-	firebaseCredentialsPath := "path/to/your/firebase-service-account.json"
-	firebase.InitFirebase(firebaseCredentialsPath)
-
-	// Set the SendGrid API key as an environment variable
-	// This is required for sending emails using the SendGrid service
-	err := os.Setenv("SENDGRID_API_KEY", "your-sendgrid-api-key")
+	// Log JSON lines (by default) with personal data masked
+	// The standard library logger is redirected too, so every line has the same shape
+	logger, err := logging.New(os.Stderr, logging.Options{
+		Level:     cfg.Logging.Level,
+		Format:    cfg.Logging.Format,
+		RedactPII: cfg.Logging.RedactPII,
+	})
 	if err != nil {
-		log.Fatalf("Failed to set SENDGRID_API_KEY: %v", err)
+		fatal("invalid logging configuration", err)
 	}
+	slog.SetDefault(logger)
 
-	// Initialize PostgreSQL connection
-	// The DSN (Data Source Name) contains the database connection details
-	dsn := "host=localhost user=postgres password=mysecretpassword dbname=mydb port=5432 sslmode=disable"
-	store, err := store.NewPostgresUserStore(dsn)
-	// Uncomment the line below if using a different user store implementation
-	// store, err := store.NewUserStore()
+	// Subcommands run instead of the server
+	// "migrate up|down|status" manages the database schema
+	// "config" prints the effective configuration with secrets redacted
+	if len(args) > 0 {
+		switch args[0] {
+		case "migrate":
+			if err := runMigrate(cfg, args[1:]); err != nil {
+				fatal("migration failed", err)
+			}
+		case "config":
+			fmt.Print(cfg)
+		default:
+			fatal("unknown command", fmt.Errorf("%q", args[0]))
+		}
+		return
+	}
+	slog.Info("configuration loaded", "config", cfg)
+
+	// Stop on SIGINT (Ctrl-C) or SIGTERM (sent by orchestrators before killing the process)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// The manager drains the HTTP server on shutdown, then stops each component
+	// registered below in reverse order: the outbox relay, the notification
+	// queue and finally the database
+	manager := &lifecycle.Manager{ShutdownTimeout: cfg.HTTP.ShutdownTimeout}
+
+	// Export traces of requests, store calls and notification sends
+	shutdownTracing, err := tracing.Setup(ctx, cfg.Tracing)
 	if err != nil {
-		log.Fatalf("Failed to connect to DB: %v", err)
+		fatal("failed to set up tracing", err)
+	}
+	manager.OnShutdown("tracing", shutdownTracing) // Registered first so spans from every other step are flushed.
+
+	// Dependencies probed by /readyz
+	checks := health.NewRegistry(2 * time.Second)
+
+	// Prometheus metrics for routes, store calls and notification sends
+	stats := metrics.New()
+
+	// Initialize the user store selected by the configuration
+	var userStore store.UserStoreInterface
+	var pg *store.PostgresUserStore
+	switch cfg.Store.Backend {
+	case config.BackendPostgres:
+		pg, err = store.NewPostgresUserStore(cfg.Store.DSN)
+		if err != nil {
+			fatal("failed to connect to DB", err)
+		}
+
+		// Bring the schema up to date before serving
+		// The migrator holds an advisory lock, so replicas starting together are safe
+		if cfg.Store.MigrateOnStart {
+			migrator, err := pg.Migrator()
+			if err != nil {
+				fatal("failed to prepare migrations", err)
+			}
+			applied, err := migrator.Up(context.Background())
+			if err != nil {
+				fatal("failed to migrate DB", err)
+			}
+			for _, m := range applied {
+				slog.Info("migration applied", "version", m.Version, "migration", m.Name)
+			}
+		}
+		manager.OnShutdown("database", func(context.Context) error { return pg.Close() })
+		userStore = pg
+	default:
+		userStore, _ = store.NewUserStore()
+	}
+	userStore = tracing.TraceStore(stats.InstrumentStore(userStore))
+	if checker, ok := userStore.(health.Checker); ok {
+		checks.Register("store", checker)
+	}
+
+	// Build the enabled notification channels
+	targets := map[string]notification.Notifier{}
+	if cfg.Notifiers.FCM.Enabled {
+		// Initialize Firebase with the service account JSON file
+		if err := firebase.InitFirebase(cfg.Notifiers.FCM.CredentialsFile); err != nil {
+			fatal("failed to initialize Firebase", err)
+		}
+		fcm := &notification.FCMNotifier{Client: firebase.FCMClient, Topic: cfg.Notifiers.FCM.Topic}
+		targets["fcm"] = tracing.TraceNotifier("fcm", stats.InstrumentNotifier("fcm", fcm))
+		checks.Register("fcm", fcm)
+	}
+	if cfg.Notifiers.Email.Enabled {
+		// SendEmail reads the key from the environment. The configuration already
+		// prefers SENDGRID_API_KEY when it is set, so a real key is never overwritten
+		if err := os.Setenv("SENDGRID_API_KEY", cfg.Notifiers.Email.SendGridAPIKey); err != nil {
+			fatal("failed to set SENDGRID_API_KEY", err)
+		}
+		email := notification.EmailNotifier{Templates: notification.DefaultTemplates()}
+		targets["email"] = tracing.TraceNotifier("email", stats.InstrumentNotifier("email", email))
+		checks.Register("email", email)
+	}
+
+	// Email new and changed addresses a signed link confirming them
+	// The links go through the queue as their own target, so failed sends are retried
+	// and dead-lettered like notifications; each link is only created when it is sent,
+	// so its token never ends up in dead letters
+	// The target only receives the links, not the user events broadcast to the others
+	var verification *handler.EmailVerification
+	if cfg.Verification.Enabled {
+		verification = &handler.EmailVerification{
+			Tokens: &auth.VerificationTokens{Secret: []byte(cfg.Verification.Secret), TTL: cfg.Verification.TTL},
+			URL:    cfg.Verification.URL,
+			Mailer: notification.EmailNotifier{Templates: notification.DefaultTemplates()},
+		}
+		targets[handler.VerificationTarget] = notification.TargetOnly(tracing.TraceNotifier(handler.VerificationTarget, stats.InstrumentNotifier(handler.VerificationTarget, verification)))
+	}
+
+	// Deliver notifications in the background so CreateUser responds immediately
+	// Each channel is retried on its own; permanent failures are kept in a
+	// dead-letter store that operators can inspect and replay
+	var deadLetters notification.DeadLetterStore = notification.NewMemoryDeadLetters()
+	if cfg.Notifiers.DeadLetterFile != "" {
+		deadLetters, err = notification.NewFileDeadLetters(cfg.Notifiers.DeadLetterFile)
+		if err != nil {
+			fatal("failed to open dead-letter store", err)
+		}
+	}
+	queue := notification.NewQueue(targets, deadLetters, notification.DefaultQueueConfig)
+	manager.OnShutdown("notification queue", queue.Close) // Flush pending sends; leftovers are dead-lettered.
+	checks.Register("notification queue", queue)
+	if verification != nil {
+		verification.Notifier = queue.Target(handler.VerificationTarget)
 	}
 
 	// Create a new UserHandler with the initialized store
-	// This handler will manage user-related operations
-	userHandler := &handler.UserHandler{Store: store}
+	// This handler will manage user-related operations, giving each request a
+	// deadline so a stuck query cannot hold a connection forever
+	userHandler := &handler.UserHandler{
+		Store:          userStore,
+		RequestTimeout: cfg.HTTP.RequestTimeout,
+		Verification:   verification,
+	}
+	if pg != nil {
+		// Notifications are not sent by the handler: PostgresUserStore records
+		// them in its outbox and the relay publishes them once committed
+		// The relay waits for the queue to deliver or dead-letter each event before
+		// marking it delivered, so events still queued at a crash are sent again
+		relayCtx, stopRelay := context.WithCancel(context.Background())
+		relayDone := make(chan struct{})
+		go func() {
+			defer close(relayDone)
+			pg.NewOutboxRelay(queue.Synchronous(), time.Second).Run(relayCtx)
+		}()
+		manager.OnShutdown("outbox relay", func(ctx context.Context) error {
+			stopRelay() // Undelivered events stay in the outbox for the next start.
+			select {
+			case <-relayDone:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+	} else {
+		userHandler.Notifier = queue
+	}
+
+	// Require a JWT bearer token on the API, signed with the shared secret or a key from the JWKS file
+	// The roles in the token then decide which operations the caller may perform
+	// Machine clients may send an API key instead, which grants the key's scopes
+	var routerOpts []router.Option
+	var apiKeys store.APIKeyStoreInterface = store.NewAPIKeyStore()
+	if pg != nil {
+		apiKeys = pg.APIKeys()
+	}
+	if cfg.Auth.Enabled {
+		verifier, err := auth.LoadJWTVerifier(cfg.Auth)
+		if err != nil {
+			fatal("failed to set up authentication", err)
+		}
+		routerOpts = append(routerOpts,
+			router.WithAuthenticator("Bearer", verifier),
+			router.WithAuthenticator("ApiKey", &auth.APIKeyAuthenticator{Store: apiKeys}),
+		)
+
+		policy, err := authz.NewPolicy(cfg.Authz.Roles)
+		if err != nil {
+			fatal("invalid authorization policy", err)
+		}
+		userHandler.Policy = policy
+		slog.Info("authorization policy loaded", "roles", policy.Roles())
 
-	// Initialize the router with the UserHandler
+		// The dead-letter and API key admin endpoints are only served behind the policy
+		// A nil policy allows everything, so without auth they would be open to anyone
+		routerOpts = append(routerOpts,
+			router.WithDeadLetters(&handler.DeadLetterHandler{Queue: queue, Policy: policy}),
+			router.WithAPIKeys(&handler.APIKeyHandler{Store: apiKeys, Policy: policy}),
+		)
+
+		// Users may also log in with a password, for a session token signed with the shared secret
+		// Repeated failures lock the account, whether made at login or when changing the password
+		userHandler.Lockout = auth.NewLockout(cfg.Auth.MaxLoginAttempts, cfg.Auth.LockoutDuration)
+		if cfg.Auth.HMACSecret != "" {
+			issuer, err := auth.NewTokenIssuer(cfg.Auth)
+			if err != nil {
+				fatal("failed to set up logins", err)
+			}
+			routerOpts = append(routerOpts, router.WithLogin(&handler.LoginHandler{Store: userStore, Issuer: issuer, Lockout: userHandler.Lockout}))
+		} else {
+			slog.Warn("password logins are disabled; they need auth.hmac_secret to sign session tokens")
+		}
+	} else {
+		slog.Warn("authentication is disabled; anyone who can reach the server can manage users, and the admin endpoints are off")
+	}
+
+	// Initialize the router with the UserHandler and the probes
 	// The router will handle incoming HTTP requests and route them to the appropriate handlers
-	r := router.NewRouter(userHandler)
+	routerOpts = append(routerOpts,
+		router.WithHealth(checks),
+		router.WithMetrics(stats),
+		router.WithTracing(),
+		router.WithLogger(logger),
+	)
+	r := router.NewRouter(userHandler, routerOpts...)
+
+	// Start the HTTP server on the configured address
+	// Timeouts stop slow or idle clients from holding connections open
+	manager.Server = &http.Server{
+		Addr:              cfg.HTTP.Addr,
+		Handler:           r,
+		ReadHeaderTimeout: cfg.HTTP.ReadTimeout,
+		ReadTimeout:       cfg.HTTP.ReadTimeout,
+		WriteTimeout:      cfg.HTTP.WriteTimeout,
+		IdleTimeout:       cfg.HTTP.IdleTimeout,
+	}
+
+	// Serve until a signal arrives, then drain requests and background work
+	if err := manager.Run(ctx); err != nil {
+		fatal("server stopped with errors", err)
+	}
+	slog.Info("server stopped")
+}
 
-	// Start the HTTP server on port 8080
-	// Log a message indicating the server has started and handle any fatal errors
-	log.Println("Server started at :8080")
-	log.Fatal(http.ListenAndServe(":8080", r))
+// fatal logs an error that prevents the service from running and exits.
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
 }
@@ -0,0 +1,138 @@
+// Package metrics exposes Prometheus metrics for HTTP routes, store
+// operations and notification sends.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// namespace prefixes every metric name.
+const namespace = "curd"
+
+// Metrics holds the collectors of one registry. Use a separate Metrics per
+// test so counts do not leak between them.
+type Metrics struct {
+	Registry *prometheus.Registry
+
+	httpRequests  *prometheus.CounterVec
+	httpDuration  *prometheus.HistogramVec
+	storeDuration *prometheus.HistogramVec
+	storeErrors   *prometheus.CounterVec
+	notifications *prometheus.CounterVec
+}
+
+// New returns metrics registered on a new registry, along with the Go
+// runtime and process collectors.
+func New() *Metrics {
+	m := &Metrics{
+		Registry: prometheus.NewRegistry(),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: "http", Name: "requests_total",
+			Help: "HTTP requests handled, by route, method and status code.",
+		}, []string{"route", "method", "status"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace, Subsystem: "http", Name: "request_duration_seconds",
+			Help:    "Time to handle HTTP requests, by route, method and status code.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"route", "method", "status"}),
+		storeDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace, Subsystem: "store", Name: "operation_duration_seconds",
+			Help:    "Time spent in user store methods, by method.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method"}),
+		storeErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: "store", Name: "errors_total",
+			Help: "User store calls that returned an error, by method and kind of error.",
+		}, []string{"method", "kind"}),
+		notifications: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: "notification", Name: "sends_total",
+			Help: "Notification send attempts, by channel and outcome (success or failure).",
+		}, []string{"channel", "outcome"}),
+	}
+	m.Registry.MustRegister(
+		m.httpRequests, m.httpDuration, m.storeDuration, m.storeErrors, m.notifications,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return m
+}
+
+// Handler serves the metrics in the Prometheus text exposition format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.Registry, promhttp.HandlerOpts{Registry: m.Registry})
+}
+
+// Middleware counts and times every request passing through next.
+func (m *Metrics) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		labels := prometheus.Labels{"route": route(r, rec.status), "method": r.Method, "status": strconv.Itoa(rec.status)}
+		m.httpRequests.With(labels).Inc()
+		m.httpDuration.With(labels).Observe(time.Since(start).Seconds())
+	})
+}
+
+// subresources are the literal path segments the handlers route on below a
+// subtree pattern, e.g. "password" in "/users/{id}/password".
+var subresources = map[string]bool{"password": true, "verify": true, "rotate": true, "replay": true}
+
+// maxRouteDepth is how many segments below a subtree pattern are labelled,
+// enough for "{id}/password"; deeper paths are labelled with the pattern.
+const maxRouteDepth = 2
+
+// route returns a low-cardinality label for the request, built from the
+// pattern it matched rather than its path, e.g. "/users/{id}" for
+// "/users/42". router.NewRouter records the pattern before the middleware
+// run. Below a subtree pattern such as "/users/", every segment other than a
+// known subresource becomes "{id}", so arbitrary URLs, whatever their status,
+// cannot create new series.
+func route(r *http.Request, status int) string {
+	if r.Pattern == "" {
+		return "unmatched"
+	}
+	pattern := r.Pattern
+	if _, path, hasMethod := strings.Cut(pattern, " "); hasMethod {
+		pattern = path // The method is a label of its own.
+	}
+	if status == http.StatusNotFound || !strings.HasSuffix(pattern, "/") {
+		return pattern
+	}
+
+	segments := strings.Split(strings.TrimPrefix(r.URL.Path, pattern), "/")
+	if len(segments) > maxRouteDepth {
+		return pattern
+	}
+	for i, s := range segments {
+		if s != "" && !subresources[s] {
+			segments[i] = "{id}"
+		}
+	}
+	return pattern + strings.Join(segments, "/")
+}
+
+// statusRecorder captures the status code written by a handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+// WriteHeader records the status code before writing it.
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
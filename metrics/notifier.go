@@ -0,0 +1,40 @@
+package metrics
+
+import (
+	"Curd/notification"
+	"context"
+)
+
+// instrumentedNotifier is a notification.Notifier that counts the successful
+// and failed sends of the notifier it wraps.
+type instrumentedNotifier struct {
+	channel string
+	next    notification.Notifier
+	metrics *Metrics
+}
+
+// InstrumentNotifier wraps the notifier for a channel (e.g. "fcm" or
+// "email") so every send attempt is counted by outcome. The wrapper also
+// forwards health checks when next supports them.
+func (m *Metrics) InstrumentNotifier(channel string, next notification.Notifier) notification.Notifier {
+	return &instrumentedNotifier{channel: channel, next: next, metrics: m}
+}
+
+// Notify sends through the wrapped notifier and counts the outcome.
+func (n *instrumentedNotifier) Notify(ctx context.Context, event notification.Event) error {
+	err := n.next.Notify(ctx, event)
+	outcome := "success"
+	if err != nil {
+		outcome = "failure"
+	}
+	n.metrics.notifications.WithLabelValues(n.channel, outcome).Inc()
+	return err
+}
+
+// Check forwards to the wrapped notifier's health check, if it has one.
+func (n *instrumentedNotifier) Check(ctx context.Context) error {
+	if checker, ok := n.next.(interface{ Check(context.Context) error }); ok {
+		return checker.Check(ctx)
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package metrics
+
+import (
+	"Curd/model"
+	"Curd/store"
+	"context"
+	"errors"
+	"time"
+)
+
+// instrumentedStore is a store.UserStoreInterface that records the latency
+// and errors of every call to the store it wraps.
+type instrumentedStore struct {
+	next    store.UserStoreInterface
+	metrics *Metrics
+}
+
+// InstrumentStore wraps a user store so each method reports its latency and
+// errors. The wrapper also forwards health checks when next supports them.
+func (m *Metrics) InstrumentStore(next store.UserStoreInterface) store.UserStoreInterface {
+	return &instrumentedStore{next: next, metrics: m}
+}
+
+// observe records one call to method that started at start and returned err.
+func (s *instrumentedStore) observe(method string, start time.Time, err error) {
+	s.metrics.storeDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+	if err != nil {
+		s.metrics.storeErrors.WithLabelValues(method, errorKind(err)).Inc()
+	}
+}
+
+// CreateUser records the latency and outcome of the wrapped CreateUser.
+func (s *instrumentedStore) CreateUser(ctx context.Context, user model.User) (model.User, error) {
+	start := time.Now()
+	created, err := s.next.CreateUser(ctx, user)
+	s.observe("CreateUser", start, err)
+	return created, err
+}
+
+// GetUser records the latency and outcome of the wrapped GetUser.
+func (s *instrumentedStore) GetUser(ctx context.Context, id int) (model.User, error) {
+	start := time.Now()
+	user, err := s.next.GetUser(ctx, id)
+	s.observe("GetUser", start, err)
+	return user, err
+}
+
+// UpdateUser records the latency and outcome of the wrapped UpdateUser.
+func (s *instrumentedStore) UpdateUser(ctx context.Context, id int, user model.User, ifVersion int) (model.User, bool, error) {
+	start := time.Now()
+	updated, emailChanged, err := s.next.UpdateUser(ctx, id, user, ifVersion)
+	s.observe("UpdateUser", start, err)
+	return updated, emailChanged, err
+}
+
+// DeleteUser records the latency and outcome of the wrapped DeleteUser.
+func (s *instrumentedStore) DeleteUser(ctx context.Context, id int, ifVersion int) error {
+	start := time.Now()
+	err := s.next.DeleteUser(ctx, id, ifVersion)
+	s.observe("DeleteUser", start, err)
+	return err
+}
+
+// GetAllUser records the latency and outcome of the wrapped GetAllUser.
+func (s *instrumentedStore) GetAllUser(ctx context.Context) ([]model.User, error) {
+	start := time.Now()
+	users, err := s.next.GetAllUser(ctx)
+	s.observe("GetAllUser", start, err)
+	return users, err
+}
+
+// ListUsers records the latency and outcome of the wrapped ListUsers.
+func (s *instrumentedStore) ListUsers(ctx context.Context, opts store.ListOptions) (store.ListResult, error) {
+	start := time.Now()
+	result, err := s.next.ListUsers(ctx, opts)
+	s.observe("ListUsers", start, err)
+	return result, err
+}
+
+// GetUserByEmail records the latency and outcome of the wrapped GetUserByEmail.
+func (s *instrumentedStore) GetUserByEmail(ctx context.Context, email string) (model.User, error) {
+	start := time.Now()
+	user, err := s.next.GetUserByEmail(ctx, email)
+	s.observe("GetUserByEmail", start, err)
+	return user, err
+}
+
+// SetPassword records the latency and outcome of the wrapped SetPassword.
+func (s *instrumentedStore) SetPassword(ctx context.Context, id int, hash string) error {
+	start := time.Now()
+	err := s.next.SetPassword(ctx, id, hash)
+	s.observe("SetPassword", start, err)
+	return err
+}
+
+// VerifyEmail records the latency and outcome of the wrapped VerifyEmail.
+func (s *instrumentedStore) VerifyEmail(ctx context.Context, id int, email string) (model.User, error) {
+	start := time.Now()
+	user, err := s.next.VerifyEmail(ctx, id, email)
+	s.observe("VerifyEmail", start, err)
+	return user, err
+}
+
+// Check forwards to the wrapped store's health check, if it has one.
+func (s *instrumentedStore) Check(ctx context.Context) error {
+	if checker, ok := s.next.(interface{ Check(context.Context) error }); ok {
+		return checker.Check(ctx)
+	}
+	return nil
+}
+
+// errorKind classifies a store error for the "kind" label.
+func errorKind(err error) string {
+	switch {
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, store.ErrNotFound):
+		return "not_found"
+	case errors.Is(err, store.ErrVersionMismatch):
+		return "version_mismatch"
+	case errors.Is(err, store.ErrConflict):
+		return "conflict"
+	case errors.Is(err, store.ErrValidation):
+		return "validation"
+	case errors.Is(err, store.ErrUnavailable):
+		return "unavailable"
+	}
+	return "other"
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"Curd/config"
+	"Curd/store"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+)
+
+// runMigrate implements the "migrate" subcommand:
+//
+//	migrate up        apply every pending migration
+//	migrate down [n]  revert the last n applied migrations (default 1)
+//	migrate status    list migrations and when they were applied
+func runMigrate(cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: migrate up | down [n] | status")
+	}
+
+	if cfg.Store.Backend != config.BackendPostgres {
+		return fmt.Errorf("migrations need the %s store backend, not %s", config.BackendPostgres, cfg.Store.Backend)
+	}
+	pg, err := store.NewPostgresUserStore(cfg.Store.DSN)
+	if err != nil {
+		return err
+	}
+	migrator, err := pg.Migrator()
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, m := range applied {
+			fmt.Printf("applied %04d_%s\n", m.Version, m.Name)
+		}
+		if err == nil && len(applied) == 0 {
+			fmt.Println("no pending migrations")
+		}
+		return err
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				return fmt.Errorf("invalid step count %q", args[1])
+			}
+		}
+		reverted, err := migrator.Down(ctx, steps)
+		for _, m := range reverted {
+			fmt.Printf("reverted %04d_%s\n", m.Version, m.Name)
+		}
+		return err
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "VERSION\tNAME\tAPPLIED")
+		for _, s := range statuses {
+			applied := "pending"
+			if s.AppliedAt != nil {
+				applied = s.AppliedAt.Local().Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(tw, "%04d\t%s\t%s\n", s.Version, s.Name, applied)
+		}
+		return tw.Flush()
+	default:
+		return fmt.Errorf("unknown migrate command %q", args[0])
+	}
+}
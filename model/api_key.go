@@ -0,0 +1,58 @@
+package model
+
+import (
+	"log/slog"
+	"time"
+)
+
+// APIKey is a long-lived credential for machine clients such as batch jobs.
+// Only a hash of the key is kept; the key itself is shown once, when it is
+// created or rotated.
+type APIKey struct {
+	// ID is the primary key of the api_keys table.
+	ID int `json:"id" gorm:"primaryKey;autoIncrement"`
+
+	// Name describes what the key is for, e.g. "nightly-export".
+	Name string `json:"name" gorm:"not null"`
+
+	// Prefix is the public start of the key, shown in listings and logs so
+	// keys can be told apart without revealing them.
+	Prefix string `json:"prefix" gorm:"not null"`
+
+	// Hash is the hex-encoded SHA-256 of the key. It is never serialized.
+	Hash string `json:"-" gorm:"not null;uniqueIndex:idx_api_keys_hash"`
+
+	// Scopes are the permissions the key grants, e.g. "users:list".
+	Scopes []string `json:"scopes" gorm:"type:jsonb;serializer:json;not null"`
+
+	// ExpiresAt is when the key stops working; nil means it never expires.
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+
+	// CreatedAt is when the key was created.
+	CreatedAt time.Time `json:"created_at" gorm:"not null"`
+
+	// RotatedAt is when the key was last replaced by a new secret, if ever.
+	RotatedAt *time.Time `json:"rotated_at,omitempty"`
+
+	// RevokedAt is when the key was revoked. Revoked keys are kept for auditing.
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// TableName sets the table used for API keys.
+func (APIKey) TableName() string {
+	return "api_keys"
+}
+
+// Active reports whether the key may be used at the given time.
+func (k APIKey) Active(now time.Time) bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || now.Before(*k.ExpiresAt))
+}
+
+// LogValue logs a key by its ID, name and prefix, never its hash.
+func (k APIKey) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.Int("id", k.ID),
+		slog.String("key_name", k.Name),
+		slog.String("prefix", k.Prefix),
+	)
+}
@@ -1,15 +1,51 @@
 package model
 
+import "log/slog"
+
 // User represents the structure of a user in the system.
 // It includes fields for ID, Name, and Email.
 type User struct {
 	// ID is the primary key for the User table in the database.
-	// It is auto-incremented by GORM.
-	ID int `gorm:"primaryKey;autoIncrement"`
+	// It is auto-incremented by GORM and is the tie-breaker in the
+	// composite indexes used for keyset pagination.
+	ID int `gorm:"primaryKey;autoIncrement;index:idx_users_name_id,priority:2;index:idx_users_email_id,priority:2"`
 
 	// Name is the name of the user.
-	Name string `json:"name"`
+	// It is trimmed and must be 1-100 characters long.
+	Name string `json:"name" gorm:"index:idx_users_name_id,priority:1" normalize:"trim" validate:"required,max=100"`
 
 	// Email is the email address of the user.
-	Email string `json:"email"`
+	// It is trimmed, lowercased and must be a valid address.
+	Email string `json:"email" gorm:"index:idx_users_email_id,priority:1" normalize:"trim,lower" validate:"required,email,max=254"`
+
+	// Locale is the user's preferred language as a BCP 47 tag, e.g. "pt-BR".
+	// Notifications fall back to less specific locales, then to English.
+	Locale string `json:"locale,omitempty" normalize:"trim" validate:"locale,max=35"`
+
+	// Version starts at 1 and is incremented by the store on every write.
+	// It backs the ETag used for optimistic concurrency control.
+	Version int `json:"version" gorm:"not null;default:1"`
+
+	// EmailVerified reports whether the user confirmed the email through the
+	// link sent to it. Only the verification endpoint sets it; the store
+	// clears it whenever the email changes and ignores it in request bodies.
+	EmailVerified bool `json:"email_verified" gorm:"not null;default:false"`
+
+	// PasswordHash is the argon2id hash of the user's password, empty until
+	// one is set. It is never serialized, so it cannot leak through the API
+	// or be set through a user body; use the password endpoint instead.
+	PasswordHash string `json:"-" gorm:"not null;default:''"`
+}
+
+// LogValue logs a user as a group of its fields. Loggers built by the
+// logging package mask the name and email unless PII redaction is disabled.
+func (u User) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.Int("id", u.ID),
+		slog.String("name", u.Name),
+		slog.String("email", u.Email),
+		slog.Bool("email_verified", u.EmailVerified),
+		slog.String("locale", u.Locale),
+		slog.Int("version", u.Version),
+	)
 }
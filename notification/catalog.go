@@ -0,0 +1,146 @@
+package notification
+
+import (
+	"Curd/model"
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+	"sync"
+	texttemplate "text/template"
+)
+
+// DefaultLocale is the last locale in every fallback chain.
+const DefaultLocale = "en"
+
+//go:embed locales/*.json
+var embeddedLocales embed.FS
+
+// Catalog holds notification copy for several locales. Each message is a
+// text/template executed with the recipient model.User as data.
+type Catalog struct {
+	messages map[string]map[string]*texttemplate.Template // locale -> key -> message
+}
+
+// LoadCatalog parses every "<locale>.json" file in the root of fsys.
+// Each file is a flat JSON object mapping message keys to message templates.
+func LoadCatalog(fsys fs.FS) (*Catalog, error) {
+	c := &Catalog{messages: make(map[string]map[string]*texttemplate.Template)}
+
+	files, err := fs.Glob(fsys, "*.json")
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		data, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+		var raw map[string]string
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return nil, fmt.Errorf("parse locale %s: %w", file, err)
+		}
+
+		locale := canonicalLocale(strings.TrimSuffix(file, path.Ext(file)))
+		c.messages[locale] = make(map[string]*texttemplate.Template, len(raw))
+		for key, text := range raw {
+			tmpl, err := texttemplate.New(key).Option("missingkey=error").Parse(text)
+			if err != nil {
+				return nil, fmt.Errorf("parse message %s in %s: %w", key, file, err)
+			}
+			c.messages[locale][key] = tmpl
+		}
+	}
+	return c, nil
+}
+
+// DefaultCatalog returns the message catalog embedded in the binary.
+// It is parsed once, on first use.
+var DefaultCatalog = sync.OnceValue(func() *Catalog {
+	sub, err := fs.Sub(embeddedLocales, "locales")
+	if err != nil {
+		panic(err)
+	}
+	c, err := LoadCatalog(sub)
+	if err != nil {
+		panic(err) // The embedded catalog is fixed at build time.
+	}
+	return c
+})
+
+// Chain returns the locales consulted for a requested locale, most specific
+// first, e.g. "pt-BR" -> ["pt-BR", "pt", "en"]. An empty locale yields ["en"].
+func (c *Catalog) Chain(locale string) []string {
+	var chain []string
+	for tag := canonicalLocale(locale); tag != ""; {
+		chain = append(chain, tag)
+		i := strings.LastIndex(tag, "-")
+		if i < 0 {
+			break
+		}
+		tag = tag[:i] // Drop the most specific subtag.
+	}
+	if len(chain) == 0 || chain[len(chain)-1] != DefaultLocale {
+		chain = append(chain, DefaultLocale)
+	}
+	return chain
+}
+
+// Message renders the message key for the user's locale, walking the
+// fallback chain until a locale defines the key.
+func (c *Catalog) Message(key string, user model.User) (string, error) {
+	for _, locale := range c.Chain(user.Locale) {
+		tmpl, ok := c.messages[locale][key]
+		if !ok {
+			continue
+		}
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, user); err != nil {
+			return "", fmt.Errorf("render message %s (%s): %w", key, locale, err)
+		}
+		return buf.String(), nil
+	}
+	return "", fmt.Errorf("no message %q for locale %q", key, user.Locale)
+}
+
+// canonicalLocale normalizes a BCP 47-style tag: "pt_br" -> "pt-BR", "EN" -> "en".
+func canonicalLocale(locale string) string {
+	parts := strings.FieldsFunc(locale, func(r rune) bool { return r == '-' || r == '_' })
+	for i, part := range parts {
+		switch {
+		case i == 0:
+			parts[i] = strings.ToLower(part) // Language
+		case len(part) == 2:
+			parts[i] = strings.ToUpper(part) // Region
+		case len(part) == 4:
+			parts[i] = strings.ToUpper(part[:1]) + strings.ToLower(part[1:]) // Script
+		default:
+			parts[i] = strings.ToLower(part)
+		}
+	}
+	return strings.Join(parts, "-")
+}
+
+// TemplateData is the data passed to email templates. It exposes the
+// recipient's fields directly (e.g. {{.Name}}) and localized copy through T
+// (e.g. {{.T "email.greeting"}}).
+type TemplateData struct {
+	model.User
+	Link string // Action link, e.g. the email verification link; empty for event emails.
+
+	catalog *Catalog
+}
+
+// T renders a catalog message in the recipient's locale. Data built
+// without a catalog, e.g. by templates loaded with a nil one, uses
+// DefaultCatalog, whose fallback chain ends in English.
+func (d TemplateData) T(key string) (string, error) {
+	catalog := d.catalog
+	if catalog == nil {
+		catalog = DefaultCatalog()
+	}
+	return catalog.Message(key, d.User)
+}
@@ -0,0 +1,163 @@
+package notification
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// ErrDeadLetterNotFound is returned when a dead letter ID does not exist.
+var ErrDeadLetterNotFound = errors.New("dead letter not found")
+
+// DeadLetter is an event that could not be delivered to one target after all retries.
+type DeadLetter struct {
+	ID        string    `json:"id"`         // Unique identifier used to replay the entry.
+	Target    string    `json:"target"`     // Name of the notifier that failed, e.g. "email".
+	Event     Event     `json:"event"`      // The undelivered event.
+	Attempts  int       `json:"attempts"`   // Number of delivery attempts made.
+	LastError string    `json:"last_error"` // Error returned by the final attempt.
+	FailedAt  time.Time `json:"failed_at"`  // When the event was given up on.
+}
+
+// DeadLetterStore keeps undelivered events so operators can inspect and replay them.
+type DeadLetterStore interface {
+	Add(letter DeadLetter) error          // Record a failed delivery.
+	List() ([]DeadLetter, error)          // Return every recorded failure, oldest first.
+	Remove(id string) (DeadLetter, error) // Delete and return a failure, e.g. to replay it.
+}
+
+// MemoryDeadLetters is a DeadLetterStore that keeps entries in memory only.
+type MemoryDeadLetters struct {
+	mu      sync.Mutex
+	letters []DeadLetter
+}
+
+// NewMemoryDeadLetters returns an empty in-memory dead-letter store.
+func NewMemoryDeadLetters() *MemoryDeadLetters {
+	return &MemoryDeadLetters{}
+}
+
+// Add records a failed delivery.
+func (m *MemoryDeadLetters) Add(letter DeadLetter) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.letters = append(m.letters, letter)
+	return nil
+}
+
+// List returns every recorded failure, oldest first.
+func (m *MemoryDeadLetters) List() ([]DeadLetter, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]DeadLetter{}, m.letters...), nil
+}
+
+// Remove deletes and returns the failure with the given ID.
+func (m *MemoryDeadLetters) Remove(id string) (DeadLetter, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, letter := range m.letters {
+		if letter.ID == id {
+			m.letters = append(m.letters[:i], m.letters[i+1:]...)
+			return letter, nil
+		}
+	}
+	return DeadLetter{}, ErrDeadLetterNotFound
+}
+
+// FileDeadLetters is a DeadLetterStore persisted as a JSON file, so failures
+// survive restarts. The file is rewritten atomically on every change.
+type FileDeadLetters struct {
+	mem  MemoryDeadLetters
+	path string
+}
+
+// NewFileDeadLetters opens (or creates) the dead-letter file at path.
+func NewFileDeadLetters(path string) (*FileDeadLetters, error) {
+	f := &FileDeadLetters{path: path}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return f, nil // Start empty; the file is created on the first failure.
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &f.mem.letters); err != nil {
+		return nil, fmt.Errorf("parse dead letters %s: %w", path, err)
+	}
+	return f, nil
+}
+
+// Add records a failed delivery and persists the file. If the file cannot
+// be written, the letter is not recorded at all, so the entries in memory
+// never include one that would be lost on restart.
+func (f *FileDeadLetters) Add(letter DeadLetter) error {
+	f.mem.mu.Lock()
+	defer f.mem.mu.Unlock()
+	letters := append(slices.Clip(f.mem.letters), letter) // A copy, left unused if saving fails.
+	if err := f.save(letters); err != nil {
+		return err
+	}
+	f.mem.letters = letters
+	return nil
+}
+
+// List returns every recorded failure, oldest first.
+func (f *FileDeadLetters) List() ([]DeadLetter, error) {
+	return f.mem.List()
+}
+
+// Remove deletes and returns the failure with the given ID, persisting the
+// file. If the file cannot be written, the letter is kept.
+func (f *FileDeadLetters) Remove(id string) (DeadLetter, error) {
+	f.mem.mu.Lock()
+	defer f.mem.mu.Unlock()
+	for i, letter := range f.mem.letters {
+		if letter.ID == id {
+			letters := slices.Delete(slices.Clone(f.mem.letters), i, i+1)
+			if err := f.save(letters); err != nil {
+				return DeadLetter{}, err
+			}
+			f.mem.letters = letters
+			return letter, nil
+		}
+	}
+	return DeadLetter{}, ErrDeadLetterNotFound
+}
+
+// save writes the given entries to a temporary file and renames it into
+// place, so a crash never leaves a truncated file behind. Callers hold the
+// lock and only adopt the entries once they are saved.
+func (f *FileDeadLetters) save(letters []DeadLetter) error {
+	data, err := json.MarshalIndent(letters, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), filepath.Base(f.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // No-op once the rename has succeeded.
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), f.path)
+}
+
+// newID returns a random identifier for a dead letter.
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,75 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"firebase.google.com/go/messaging"
+)
+
+// ErrFCMNotConfigured is returned by FCMNotifier when it has no client.
+var ErrFCMNotConfigured = errors.New("FCM client not initialized")
+
+// FCMNotifier is a Notifier that publishes events to a Firebase Cloud Messaging topic.
+type FCMNotifier struct {
+	Client *messaging.Client // Initialized FCM client, e.g. firebase.FCMClient.
+	Topic  string            // Topic the messages are published to.
+
+	Catalog *Catalog // Localized titles and bodies; nil uses DefaultCatalog.
+}
+
+// Notify publishes a push notification describing the event.
+func (n *FCMNotifier) Notify(ctx context.Context, event Event) error {
+	if n.Client == nil {
+		return ErrFCMNotConfigured // Fail cleanly instead of panicking on a nil client.
+	}
+
+	catalog := n.Catalog
+	if catalog == nil {
+		catalog = DefaultCatalog()
+	}
+	content, err := pushContent(catalog, event)
+	if err != nil {
+		return err
+	}
+
+	message := &messaging.Message{
+		Notification: content,
+		Topic:        n.Topic,
+	}
+	if _, err := n.Client.Send(ctx, message); err != nil {
+		return fmt.Errorf("send FCM notification: %w", err)
+	}
+	return nil
+}
+
+// Check sends a dry-run message to the topic, implementing health.Checker.
+// FCM validates the credentials and the message without delivering it.
+func (n *FCMNotifier) Check(ctx context.Context) error {
+	if n.Client == nil {
+		return ErrFCMNotConfigured
+	}
+	message := &messaging.Message{
+		Notification: &messaging.Notification{Title: "health check"},
+		Topic:        n.Topic,
+	}
+	if _, err := n.Client.SendDryRun(ctx, message); err != nil {
+		return fmt.Errorf("FCM dry run: %w", err)
+	}
+	return nil
+}
+
+// pushContent returns the push notification title and body for an event,
+// in the locale of the user the event is about.
+func pushContent(catalog *Catalog, event Event) (*messaging.Notification, error) {
+	title, err := catalog.Message("push."+string(event.Type)+".title", event.User)
+	if err != nil {
+		return nil, err
+	}
+	body, err := catalog.Message("push."+string(event.Type)+".body", event.User)
+	if err != nil {
+		return nil, err
+	}
+	return &messaging.Notification{Title: title, Body: body}, nil
+}
@@ -1,41 +1,114 @@
 package notification
 
 import (
-	"log"
+	"Curd/logging"
+	"context"
+	"errors"
+	"fmt"
 	"os"
 
 	"github.com/sendgrid/sendgrid-go"
 	"github.com/sendgrid/sendgrid-go/helpers/mail"
 )
 
-// SendEmail sends an email using the SendGrid API.
+// SendEmail renders the named email template for a user and sends it using the SendGrid API.
 // Parameters:
-// - toEmail: The recipient's email address.
-// - subject: The subject of the email.
-// - body: The content of the email.
-// Returns an error if the email fails to send.
-func SendEmail(toEmail, subject, body string) error {
+// - ctx: Cancels the request to SendGrid.
+// - templates: The parsed email templates, e.g. DefaultTemplates().
+// - name: The template to render, e.g. TemplateWelcome.
+// - data: The template data, whose User is the recipient.
+// Returns an error if the template fails to render or the email fails to send.
+func SendEmail(ctx context.Context, templates *Templates, name string, data TemplateData) error {
+	// Render the subject and both bodies from the template.
+	email, err := templates.RenderData(name, data)
+	if err != nil {
+		return err
+	}
+
 	// Create the sender's email information.
 	from := mail.NewEmail("Your App Name", "your-email@example.com")
 
 	// Create the recipient's email information.
-	to := mail.NewEmail("Recipient", toEmail)
+	to := mail.NewEmail(data.Name, data.Email)
 
-	// Create a single email message with the provided subject and body.
-	message := mail.NewSingleEmail(from, subject, to, body, body)
+	// Create a single email message with the rendered subject and plaintext/HTML bodies.
+	// An empty HTML body is left out, so the email is sent as plain text only.
+	message := mail.NewSingleEmail(from, email.Subject, to, email.Text, email.HTML)
 
 	// Initialize the SendGrid client using the API key from environment variables.
 	client := sendgrid.NewSendClient(os.Getenv("SENDGRID_API_KEY"))
 
 	// Send the email and capture the response or error.
-	response, err := client.Send(message)
+	response, err := client.SendWithContext(ctx, message)
 	if err != nil {
-		// Log the error if the email fails to send.
-		log.Printf("Failed to send email: %v", err)
+		// The caller logs the returned error.
 		return err
 	}
+	if response.StatusCode >= 300 {
+		// SendGrid reports rejected requests in the status code, not as an error.
+		return fmt.Errorf("sendgrid returned status %d: %s", response.StatusCode, response.Body)
+	}
 
 	// Log the status code of the email response.
-	log.Printf("Email sent. Status Code: %d", response.StatusCode)
+	logging.FromContext(ctx).Debug("email sent", "template", name, "status", response.StatusCode)
+	return nil
+}
+
+// ErrSendGridNotConfigured is returned by EmailNotifier.Check when no API key is set.
+var ErrSendGridNotConfigured = errors.New("SENDGRID_API_KEY not set")
+
+// EmailNotifier is a Notifier that emails the affected user through SendGrid.
+type EmailNotifier struct {
+	Templates *Templates // Email templates; nil uses DefaultTemplates.
+}
+
+// Notify sends the email template matching the event to the user's address.
+func (n EmailNotifier) Notify(ctx context.Context, event Event) error {
+	name := templateFor(event.Type)
+	if name == "" {
+		return nil // Nothing to email for this event.
+	}
+
+	return n.Send(ctx, name, TemplateData{User: event.User})
+}
+
+// Mailer sends a single templated email outside of any event, e.g. a
+// verification link. The email may carry a secret, so mailers are called by
+// the notifier that creates it rather than queued themselves, which keeps the
+// secret out of dead letters.
+type Mailer interface {
+	// Send renders the named template with data and emails it to data.User.
+	Send(ctx context.Context, name string, data TemplateData) error
+}
+
+// Send renders the named template and emails it through SendEmail,
+// implementing Mailer.
+func (n EmailNotifier) Send(ctx context.Context, name string, data TemplateData) error {
+	templates := n.Templates
+	if templates == nil {
+		templates = DefaultTemplates()
+	}
+	if err := SendEmail(ctx, templates, name, data); err != nil {
+		return fmt.Errorf("send %s email: %w", name, err)
+	}
+	return nil
+}
+
+// Check verifies the SendGrid API key by listing its scopes, implementing
+// health.Checker. No email is sent.
+func (n EmailNotifier) Check(ctx context.Context) error {
+	key := os.Getenv("SENDGRID_API_KEY")
+	if key == "" {
+		return ErrSendGridNotConfigured
+	}
+	request := sendgrid.GetRequest(key, "/v3/scopes", "")
+	request.Method = "GET"
+	response, err := sendgrid.MakeRequestWithContext(ctx, request)
+	if err != nil {
+		return fmt.Errorf("sendgrid: %w", err)
+	}
+	if response.StatusCode >= 300 {
+		return fmt.Errorf("sendgrid returned status %d", response.StatusCode)
+	}
 	return nil
 }
@@ -0,0 +1,71 @@
+package notification
+
+import (
+	"Curd/logging"
+	"Curd/model"
+	"context"
+	"errors"
+	"sync"
+)
+
+// EventType identifies what happened to a user.
+type EventType string
+
+const (
+	EventUserCreated EventType = "user.created" // A new user was created.
+	EventUserUpdated EventType = "user.updated" // A user's details were changed.
+	EventUserDeleted EventType = "user.deleted" // A user was removed.
+
+	// EventEmailVerification asks for a link confirming the user's email. It is
+	// only sent to the verification target, which creates the link when sending.
+	EventEmailVerification EventType = "user.email_verification"
+)
+
+// Event describes a user lifecycle change that notifiers should announce.
+type Event struct {
+	Type EventType  `json:"type"` // What happened.
+	User model.User `json:"user"` // The user the event is about, as stored after the change.
+}
+
+// Notifier delivers user events over some channel (push, email, logs, ...).
+type Notifier interface {
+	// Notify delivers the event, returning an error if delivery failed.
+	Notify(ctx context.Context, event Event) error
+}
+
+// Multi is a Notifier that fans an event out to several notifiers concurrently.
+// Every notifier is attempted; their errors are joined together.
+type Multi []Notifier
+
+// Notify delivers the event to every notifier in the list.
+func (m Multi) Notify(ctx context.Context, event Event) error {
+	errs := make([]error, len(m))
+	var wg sync.WaitGroup
+	for i, n := range m {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = n.Notify(ctx, event) // Each notifier writes only its own slot.
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// NopNotifier is a Notifier that discards every event.
+type NopNotifier struct{}
+
+// Notify does nothing and never fails.
+func (NopNotifier) Notify(ctx context.Context, event Event) error {
+	return nil
+}
+
+// LogNotifier is a Notifier that only writes events to the standard logger.
+// It is useful for local development where no push or email service is configured.
+type LogNotifier struct{}
+
+// Notify logs the event.
+func (LogNotifier) Notify(ctx context.Context, event Event) error {
+	logging.FromContext(ctx).Info("notification", "event", event.Type, "user", event.User)
+	return nil
+}
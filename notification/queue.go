@@ -0,0 +1,390 @@
+package notification
+
+import (
+	"Curd/logging"
+	"context"
+	"errors"
+	"log/slog"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Errors returned by Queue.Notify.
+var (
+	ErrQueueFull   = errors.New("notification queue full")
+	ErrQueueClosed = errors.New("notification queue closed")
+)
+
+// QueueConfig tunes the delivery workers and retry policy of a Queue.
+type QueueConfig struct {
+	Workers        int           // Number of concurrent delivery goroutines.
+	Capacity       int           // Maximum number of pending deliveries.
+	MaxAttempts    int           // Attempts per delivery before it is dead-lettered.
+	InitialBackoff time.Duration // Delay before the first retry.
+	MaxBackoff     time.Duration // Upper bound on the delay between retries.
+	AttemptTimeout time.Duration // Deadline for a single delivery attempt.
+}
+
+// DefaultQueueConfig is a reasonable configuration for a single service instance.
+var DefaultQueueConfig = QueueConfig{
+	Workers:        4,
+	Capacity:       1024,
+	MaxAttempts:    5,
+	InitialBackoff: 500 * time.Millisecond,
+	MaxBackoff:     30 * time.Second,
+	AttemptTimeout: 10 * time.Second,
+}
+
+// delivery is one event bound for one target.
+type delivery struct {
+	target string
+	event  Event
+	trace  trace.SpanContext // Span that produced the event, so sends join its trace.
+	logger *slog.Logger      // Logger of the request that produced the event, so logs share its request ID.
+
+	// settled, when set, is called once the delivery succeeded or was
+	// dead-lettered (nil), or could be neither (the error).
+	settled func(error)
+}
+
+// settle reports the outcome of the delivery to whoever waits for it.
+func (d delivery) settle(err error) {
+	if d.settled != nil {
+		d.settled(err)
+	}
+}
+
+// TargetOnly marks a queue target that only receives the events sent to it
+// through Queue.Target, such as verification emails. Notify and Synchronous
+// do not broadcast events to it, but its dead letters can still be replayed.
+func TargetOnly(n Notifier) Notifier {
+	return targetOnly{n}
+}
+
+// targetOnly is the Notifier returned by TargetOnly.
+type targetOnly struct {
+	Notifier
+}
+
+// broadcast reports whether the target receives every event sent to Notify.
+func broadcast(n Notifier) bool {
+	_, only := n.(targetOnly)
+	return !only
+}
+
+// Queue is a Notifier that delivers events in the background.
+// Notify enqueues one delivery per target and returns immediately; a bounded
+// pool of workers retries failures with exponential backoff and moves
+// deliveries that exhaust their attempts to the dead-letter store.
+type Queue struct {
+	targets     map[string]Notifier
+	deadLetters DeadLetterStore
+	cfg         QueueConfig
+
+	mu      sync.RWMutex  // Guards closed against concurrent sends on jobs.
+	closed  bool          // Set once Close has been called.
+	jobs    chan delivery // Pending deliveries.
+	abort   chan struct{} // Closed when Close gives up waiting for the workers.
+	workers sync.WaitGroup
+}
+
+// NewQueue starts a delivery queue for the named targets.
+// Each target is retried and dead-lettered independently, so a failing email
+// provider never causes a push notification to be sent twice.
+func NewQueue(targets map[string]Notifier, deadLetters DeadLetterStore, cfg QueueConfig) *Queue {
+	if cfg.Workers <= 0 {
+		cfg.Workers = DefaultQueueConfig.Workers
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = DefaultQueueConfig.MaxAttempts
+	}
+	q := &Queue{
+		targets:     targets,
+		deadLetters: deadLetters,
+		cfg:         cfg,
+		jobs:        make(chan delivery, cfg.Capacity),
+		abort:       make(chan struct{}),
+	}
+	for i := 0; i < cfg.Workers; i++ {
+		q.workers.Add(1)
+		go q.work()
+	}
+	return q
+}
+
+// Notify enqueues the event for every target, except those marked with
+// TargetOnly, without waiting for delivery.
+// Deliveries that do not fit in the queue are dead-lettered for replay. That
+// settles them, so nil is still returned: a caller retrying on error would
+// otherwise deliver the event a second time.
+func (q *Queue) Notify(ctx context.Context, event Event) error {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	if q.closed {
+		return ErrQueueClosed
+	}
+
+	parent := trace.SpanContextFromContext(ctx)
+	logger := logging.FromContext(ctx)
+	for target, notifier := range q.targets {
+		if broadcast(notifier) {
+			q.enqueue(delivery{target: target, event: event, trace: parent, logger: logger})
+		}
+	}
+	return nil
+}
+
+// enqueue puts a delivery on the queue, dead-lettering it if the queue is
+// full. The caller holds the read lock and has checked the queue is open.
+func (q *Queue) enqueue(d delivery) {
+	select {
+	case q.jobs <- d:
+	default:
+		q.bury(d, 0, ErrQueueFull) // Keep it for replay rather than drop it.
+	}
+}
+
+// Target returns a Notifier that queues events for the named target only,
+// with the same retries and dead-lettering as Notify. Use it for events that
+// concern a single channel, such as email verification links.
+func (q *Queue) Target(name string) Notifier {
+	return targetQueue{q: q, target: name}
+}
+
+// targetQueue is the Notifier returned by Queue.Target.
+type targetQueue struct {
+	q      *Queue
+	target string
+}
+
+// Notify enqueues the event for the target without waiting for delivery.
+func (t targetQueue) Notify(ctx context.Context, event Event) error {
+	q := t.q
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	if q.closed {
+		return ErrQueueClosed
+	}
+
+	q.enqueue(delivery{target: t.target, event: event, trace: trace.SpanContextFromContext(ctx), logger: logging.FromContext(ctx)})
+	return nil
+}
+
+// Synchronous returns a Notifier that queues events like Notify but waits
+// until every target has received the event or it was dead-lettered. Use it
+// where the caller must know an event is safe before forgetting it, such as
+// the outbox relay: an event still waiting in the queue is lost on a crash.
+func (q *Queue) Synchronous() Notifier {
+	return synchronousQueue{q}
+}
+
+// synchronousQueue is the Notifier returned by Queue.Synchronous.
+type synchronousQueue struct {
+	q *Queue
+}
+
+// Notify enqueues the event for every target, waiting for room in the queue
+// rather than dead-lettering it, and then waits until each delivery has
+// settled. It returns an error if ctx ends first or a delivery could not be
+// dead-lettered; the event should then be sent again.
+func (s synchronousQueue) Notify(ctx context.Context, event Event) error {
+	q := s.q
+	q.mu.RLock()
+	if q.closed {
+		q.mu.RUnlock()
+		return ErrQueueClosed
+	}
+
+	results := make(chan error, len(q.targets)) // Buffered, so workers never block on an abandoned wait.
+	parent := trace.SpanContextFromContext(ctx)
+	logger := logging.FromContext(ctx)
+	pending := 0
+	for target, notifier := range q.targets {
+		if !broadcast(notifier) {
+			continue
+		}
+		d := delivery{target: target, event: event, trace: parent, logger: logger, settled: func(err error) { results <- err }}
+		select {
+		case q.jobs <- d:
+			pending++
+		case <-ctx.Done():
+			q.mu.RUnlock()
+			return ctx.Err()
+		}
+	}
+	q.mu.RUnlock()
+
+	var errs []error
+	for ; pending > 0; pending-- {
+		select {
+		case err := <-results:
+			errs = append(errs, err)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Check reports whether the queue accepts events, implementing health.Checker.
+// A full queue is unhealthy because new events go straight to the dead letters.
+func (q *Queue) Check(ctx context.Context) error {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	switch {
+	case q.closed:
+		return ErrQueueClosed
+	case cap(q.jobs) > 0 && len(q.jobs) == cap(q.jobs):
+		return ErrQueueFull
+	}
+	return nil
+}
+
+// DeadLetters returns the deliveries that failed permanently.
+func (q *Queue) DeadLetters() ([]DeadLetter, error) {
+	return q.deadLetters.List()
+}
+
+// Replay removes a dead letter and puts its delivery back on the queue.
+func (q *Queue) Replay(id string) error {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	if q.closed {
+		return ErrQueueClosed
+	}
+
+	letter, err := q.deadLetters.Remove(id)
+	if err != nil {
+		return err
+	}
+	select {
+	case q.jobs <- delivery{target: letter.Target, event: letter.Event, logger: slog.Default()}:
+		return nil
+	default:
+		q.deadLetters.Add(letter) // Put it back so it is not lost.
+		return ErrQueueFull
+	}
+}
+
+// Close stops accepting events and waits for pending deliveries to finish.
+// If ctx expires first, in-flight retries are abandoned and everything still
+// pending is dead-lettered, so nothing is silently dropped.
+func (q *Queue) Close(ctx context.Context) error {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return nil
+	}
+	q.closed = true
+	close(q.jobs) // Workers exit once the channel is drained.
+	q.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		q.workers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		close(q.abort) // Tell workers to stop retrying and dead-letter the rest.
+		<-done
+		return ctx.Err()
+	}
+}
+
+// work delivers queued events until the queue is closed and drained.
+func (q *Queue) work() {
+	defer q.workers.Done()
+	for d := range q.jobs {
+		q.deliver(d)
+	}
+}
+
+// deliver attempts a single delivery with exponential backoff between retries.
+func (q *Queue) deliver(d delivery) {
+	notifier, ok := q.targets[d.target]
+	if !ok {
+		q.bury(d, 0, errors.New("unknown target "+d.target))
+		return
+	}
+
+	var err error
+	for attempt := 1; attempt <= q.cfg.MaxAttempts; attempt++ {
+		// Stop early if Close has given up waiting.
+		select {
+		case <-q.abort:
+			q.bury(d, attempt-1, errors.Join(ErrQueueClosed, err))
+			return
+		default:
+		}
+
+		if err = q.attempt(notifier, d); err == nil {
+			d.settle(nil)
+			return
+		}
+		d.logger.Warn("notification attempt failed", "event", d.event.Type, "target", d.target, "attempt", attempt, "max_attempts", q.cfg.MaxAttempts, "error", err)
+		if attempt == q.cfg.MaxAttempts {
+			break
+		}
+
+		// Wait before retrying, unless the queue is being torn down.
+		select {
+		case <-time.After(q.backoff(attempt)):
+		case <-q.abort:
+		}
+	}
+	q.bury(d, q.cfg.MaxAttempts, err)
+}
+
+// attempt makes one delivery call bounded by the attempt timeout.
+// It uses a fresh context because the request that produced the event has
+// usually finished by the time the delivery runs; only its trace is kept.
+func (q *Queue) attempt(notifier Notifier, d delivery) error {
+	ctx := trace.ContextWithSpanContext(context.Background(), d.trace)
+	ctx = logging.WithLogger(ctx, d.logger)
+	if q.cfg.AttemptTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, q.cfg.AttemptTimeout)
+		defer cancel()
+	}
+	return notifier.Notify(ctx, d.event)
+}
+
+// backoff returns the delay before the given retry: exponential growth from
+// InitialBackoff, capped at MaxBackoff, with up to 50% random jitter.
+func (q *Queue) backoff(attempt int) time.Duration {
+	d := q.cfg.InitialBackoff << (attempt - 1)
+	if d <= 0 || (q.cfg.MaxBackoff > 0 && d > q.cfg.MaxBackoff) {
+		d = q.cfg.MaxBackoff
+	}
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + rand.N(d/2+1)
+}
+
+// bury moves a delivery to the dead-letter store.
+func (q *Queue) bury(d delivery, attempts int, cause error) {
+	letter := DeadLetter{
+		ID:       newID(),
+		Target:   d.target,
+		Event:    d.event,
+		Attempts: attempts,
+		FailedAt: time.Now().UTC(),
+	}
+	if cause != nil {
+		letter.LastError = cause.Error()
+	}
+	if err := q.deadLetters.Add(letter); err != nil {
+		d.logger.Error("dead-lettering notification failed", "event", d.event.Type, "target", d.target, "error", err)
+		d.settle(errors.Join(cause, err))
+		return
+	}
+	d.logger.Error("notification dead-lettered", "event", d.event.Type, "target", d.target, "dead_letter_id", letter.ID, "error", cause)
+	d.settle(nil) // Kept for replay, so the caller need not send it again.
+}
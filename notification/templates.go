@@ -0,0 +1,153 @@
+package notification
+
+import (
+	"Curd/model"
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"os"
+	"strings"
+	"sync"
+	texttemplate "text/template"
+)
+
+// Names of the email templates sent for each user event.
+const (
+	TemplateWelcome        = "welcome"
+	TemplateProfileUpdated = "profile-updated"
+	TemplateAccountDeleted = "account-deleted"
+	TemplateVerifyEmail    = "verify-email" // Sent with a verification link, not for an event.
+)
+
+// Template file suffixes. Each named template has a plaintext file, which
+// must define a "subject" template, and an optional HTML file.
+const (
+	textSuffix = ".txt.tmpl"
+	htmlSuffix = ".html.tmpl"
+)
+
+//go:embed templates/*.tmpl
+var embeddedTemplates embed.FS
+
+// Email is a rendered email ready to be sent.
+type Email struct {
+	Subject string // Subject line, from the template's "subject" definition.
+	Text    string // Plaintext body.
+	HTML    string // HTML body; empty when the template has no HTML variant, which sends text only.
+}
+
+// Templates holds the parsed plaintext and HTML email templates, keyed by
+// name, and the catalog their localized copy comes from.
+type Templates struct {
+	text    map[string]*texttemplate.Template
+	html    map[string]*htmltemplate.Template
+	catalog *Catalog
+}
+
+// LoadTemplates parses every "<name>.txt.tmpl" file in the root of fsys,
+// along with the matching "<name>.html.tmpl" file when present. Templates
+// look up localized copy in catalog.
+func LoadTemplates(fsys fs.FS, catalog *Catalog) (*Templates, error) {
+	t := &Templates{
+		text:    make(map[string]*texttemplate.Template),
+		html:    make(map[string]*htmltemplate.Template),
+		catalog: catalog,
+	}
+
+	textFiles, err := fs.Glob(fsys, "*"+textSuffix)
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range textFiles {
+		name := strings.TrimSuffix(file, textSuffix)
+
+		// The plaintext file carries both the subject and the plaintext body.
+		text, err := texttemplate.ParseFS(fsys, file)
+		if err != nil {
+			return nil, fmt.Errorf("parse email template %s: %w", file, err)
+		}
+		if text.Lookup("subject") == nil {
+			return nil, fmt.Errorf("email template %s does not define a subject", file)
+		}
+		t.text[name] = text
+
+		// The HTML variant is optional.
+		htmlFile := name + htmlSuffix
+		if _, err := fs.Stat(fsys, htmlFile); err != nil {
+			continue
+		}
+		html, err := htmltemplate.ParseFS(fsys, htmlFile)
+		if err != nil {
+			return nil, fmt.Errorf("parse email template %s: %w", htmlFile, err)
+		}
+		t.html[name] = html
+	}
+	return t, nil
+}
+
+// LoadTemplateDir parses the email templates found in a directory on disk.
+func LoadTemplateDir(dir string, catalog *Catalog) (*Templates, error) {
+	return LoadTemplates(os.DirFS(dir), catalog)
+}
+
+// DefaultTemplates returns the email templates embedded in the binary.
+// They are parsed once, on first use.
+var DefaultTemplates = sync.OnceValue(func() *Templates {
+	sub, err := fs.Sub(embeddedTemplates, "templates")
+	if err != nil {
+		panic(err)
+	}
+	t, err := LoadTemplates(sub, DefaultCatalog())
+	if err != nil {
+		panic(err) // The embedded templates are fixed at build time.
+	}
+	return t
+})
+
+// Render executes the named template for a user, in the user's locale.
+func (t *Templates) Render(name string, user model.User) (Email, error) {
+	return t.RenderData(name, TemplateData{User: user})
+}
+
+// RenderData executes the named template with data, in the locale of the
+// user it is addressed to.
+func (t *Templates) RenderData(name string, data TemplateData) (Email, error) {
+	data.catalog = t.catalog
+	text, ok := t.text[name]
+	if !ok {
+		return Email{}, fmt.Errorf("unknown email template %q", name)
+	}
+
+	var subject, body bytes.Buffer
+	if err := text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return Email{}, fmt.Errorf("render %s subject: %w", name, err)
+	}
+	if err := text.Execute(&body, data); err != nil {
+		return Email{}, fmt.Errorf("render %s text: %w", name, err)
+	}
+	email := Email{Subject: strings.TrimSpace(subject.String()), Text: body.String()}
+
+	if html, ok := t.html[name]; ok {
+		var htmlBody bytes.Buffer
+		if err := html.Execute(&htmlBody, data); err != nil {
+			return Email{}, fmt.Errorf("render %s html: %w", name, err)
+		}
+		email.HTML = htmlBody.String()
+	}
+	return email, nil
+}
+
+// templateFor returns the email template sent for an event type, or "" if none.
+func templateFor(eventType EventType) string {
+	switch eventType {
+	case EventUserCreated:
+		return TemplateWelcome
+	case EventUserUpdated:
+		return TemplateProfileUpdated
+	case EventUserDeleted:
+		return TemplateAccountDeleted
+	}
+	return ""
+}
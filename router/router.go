@@ -1,18 +1,147 @@
 package router
 
 import (
+	"Curd/auth"    // Importing the auth package for authenticating callers
 	"Curd/handler" // Importing the handler package for handling user-related requests
+	"Curd/health"  // Importing the health package for readiness probes
+	"Curd/logging" // Importing the logging package for request IDs and request-scoped loggers
+	"Curd/metrics" // Importing the metrics package for Prometheus instrumentation
+	"Curd/tracing" // Importing the tracing package for OpenTelemetry spans
+	"log/slog"     // Importing the log/slog package for structured logging
 	"net/http"     // Importing the net/http package for HTTP server and routing
 )
 
+// options is the router being built, as seen by an Option.
+type options struct {
+	mux        *http.ServeMux                    // Router that additional routes are registered on.
+	health     *health.Registry                  // Dependencies probed by /readyz.
+	middleware []func(http.Handler) http.Handler // Wrappers applied around the whole router, outermost first.
+	logger     *slog.Logger                      // Base of the request-scoped loggers.
+
+	authenticators map[string]auth.Authenticator // Accepted Authorization schemes; none disables authentication.
+	public         map[string]bool               // Route patterns served without authentication.
+}
+
+// Option customizes the router built by NewRouter.
+type Option func(o *options)
+
+// WithDeadLetters registers the operator endpoints for inspecting and
+// replaying undelivered notifications under "/admin/dead-letters".
+func WithDeadLetters(h *handler.DeadLetterHandler) Option {
+	return func(o *options) {
+		o.mux.Handle("/admin/dead-letters", h)  // Handles listing dead letters
+		o.mux.Handle("/admin/dead-letters/", h) // Handles replaying a dead letter by ID
+	}
+}
+
+// WithAPIKeys registers the endpoints for managing API keys under
+// "/admin/api-keys".
+func WithAPIKeys(h *handler.APIKeyHandler) Option {
+	return func(o *options) {
+		o.mux.Handle("/admin/api-keys", h)  // Handles creating and listing keys
+		o.mux.Handle("/admin/api-keys/", h) // Handles rotating and revoking a key by ID
+	}
+}
+
+// WithLogin registers the public "POST /auth/login" endpoint, which
+// exchanges a password for a session token.
+func WithLogin(h *handler.LoginHandler) Option {
+	return func(o *options) {
+		o.mux.Handle("POST /auth/login", h) // Handles password logins
+		o.public["POST /auth/login"] = true // Callers log in to get credentials
+	}
+}
+
+// WithHealth makes /readyz probe the checkers in the registry.
+func WithHealth(registry *health.Registry) Option {
+	return func(o *options) {
+		o.health = registry
+	}
+}
+
+// WithMetrics serves Prometheus metrics at /metrics and counts and times
+// every request.
+func WithMetrics(m *metrics.Metrics) Option {
+	return func(o *options) {
+		o.mux.Handle("GET /metrics", m.Handler()) // Handles Prometheus scrapes
+		o.public["GET /metrics"] = true           // Scrapers do not authenticate
+		o.middleware = append(o.middleware, m.Middleware)
+	}
+}
+
+// WithLogger sets the logger that request-scoped loggers are derived from.
+// Without it, slog.Default() is used.
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+// WithAuthenticator requires requests to authenticate, accepting the
+// Authorization scheme (e.g. "Bearer") verified by a. It can be given once per
+// scheme. The probes and /metrics stay public.
+func WithAuthenticator(scheme string, a auth.Authenticator) Option {
+	return func(o *options) {
+		o.authenticators[scheme] = a
+	}
+}
+
+// WithTracing starts a span for every request, continuing the caller's
+// trace when the request carries a W3C traceparent header.
+func WithTracing() Option {
+	return func(o *options) {
+		o.middleware = append(o.middleware, tracing.Middleware)
+	}
+}
+
 // NewRouter initializes and returns a new HTTP router.
-// It takes a UserHandler as a parameter to handle user-related routes.
-func NewRouter(userHandler *handler.UserHandler) http.Handler {
-	mux := http.NewServeMux() // Create a new HTTP request multiplexer (router)
+// It takes a UserHandler as a parameter to handle user-related routes,
+// followed by options that register additional routes.
+// The liveness (/healthz) and readiness (/readyz) probes are always registered,
+// and every request is given an X-Request-ID and a logger scoped to it.
+// With an authenticator, every other route requires credentials.
+func NewRouter(userHandler *handler.UserHandler, opts ...Option) http.Handler {
+	o := &options{ // Create a new HTTP request multiplexer (router)
+		mux:            http.NewServeMux(),
+		logger:         slog.Default(),
+		authenticators: make(map[string]auth.Authenticator),
+		public:         map[string]bool{"GET /healthz": true, "GET /readyz": true},
+	}
 
 	// Register the userHandler to handle requests to "/users" and "/users/"
-	mux.Handle("/users", userHandler)  // Handles requests to "/users"
-	mux.Handle("/users/", userHandler) // Handles requests to "/users/" and subpaths
+	o.mux.Handle("/users", userHandler)  // Handles requests to "/users"
+	o.mux.Handle("/users/", userHandler) // Handles requests to "/users/" and subpaths
+
+	// Verification links are followed from an email, without credentials
+	o.mux.Handle("GET /users/verify", userHandler) // Handles followed verification links
+	o.public["GET /users/verify"] = true
+
+	// Apply any optional routes
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	// Register the probes used by orchestrators
+	healthHandler := &handler.HealthHandler{Registry: o.health}
+	o.mux.HandleFunc("GET /healthz", healthHandler.Liveness) // Handles liveness probes
+	o.mux.HandleFunc("GET /readyz", healthHandler.Readiness) // Handles readiness probes
+
+	// Wrap the router in the middleware, so the first one added runs first
+	// Request logging runs after tracing so its lines carry the trace ID,
+	// and authentication after logging so rejected requests are logged too
+	o.middleware = append(o.middleware, logging.Middleware(o.logger))
+	if len(o.authenticators) > 0 {
+		o.middleware = append(o.middleware, handler.Authenticate(o.authenticators, o.public))
+	}
+	var h http.Handler = o.mux
+	for i := len(o.middleware) - 1; i >= 0; i-- {
+		h = o.middleware[i](h)
+	}
 
-	return mux // Return the configured router
+	// Resolve the matched pattern up front, so every middleware can label the
+	// request with its route even though each one passes a copy of the request on
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, r.Pattern = o.mux.Handler(r)
+		h.ServeHTTP(w, r)
+	}) // Return the configured router
 }
@@ -0,0 +1,262 @@
+package store
+
+import (
+	"Curd/model"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Errors returned by every APIKeyStoreInterface implementation, besides the
+// kinds shared with the user store such as ErrUnavailable.
+var (
+	ErrAPIKeyNotFound = errors.New("API key not found")   // No key has the ID or hash.
+	ErrAPIKeyRevoked  = errors.New("API key was revoked") // The key can no longer be rotated.
+)
+
+// APIKeyStoreInterface persists API keys. Keys are looked up by the hash of
+// their secret, which callers compute; stores never see the secret itself.
+type APIKeyStoreInterface interface {
+	CreateAPIKey(ctx context.Context, key model.APIKey) (model.APIKey, error)            // Store a new key
+	GetAPIKey(ctx context.Context, id int) (model.APIKey, error)                         // Find the key with the ID, revoked or not
+	GetAPIKeyByHash(ctx context.Context, hash string) (model.APIKey, error)              // Find the key with the hash, revoked or not
+	ListAPIKeys(ctx context.Context) ([]model.APIKey, error)                             // Retrieve every key, oldest first
+	RotateAPIKey(ctx context.Context, id int, prefix, hash string) (model.APIKey, error) // Replace the secret of a key
+	RevokeAPIKey(ctx context.Context, id int) (model.APIKey, error)                      // Disable a key for good
+}
+
+// apiKeyNotFound returns the error reported when no key has the ID.
+func apiKeyNotFound(op string, id int) error {
+	return &Error{Op: op, Kind: ErrAPIKeyNotFound, Err: fmt.Errorf("id %d", id)}
+}
+
+// APIKeyStore is an in-memory implementation of APIKeyStoreInterface.
+type APIKeyStore struct {
+	sync.Mutex
+	keys   map[int]model.APIKey // Keys by ID
+	byHash map[string]int       // Map from the hash of a key to its ID
+	nextID int                  // Counter to generate unique key IDs
+}
+
+// NewAPIKeyStore initializes and returns a new APIKeyStore instance.
+func NewAPIKeyStore() *APIKeyStore {
+	return &APIKeyStore{
+		keys:   make(map[int]model.APIKey),
+		byHash: make(map[string]int),
+		nextID: 1,
+	}
+}
+
+// CreateAPIKey stores a new key and assigns it a unique ID.
+func (s *APIKeyStore) CreateAPIKey(ctx context.Context, key model.APIKey) (model.APIKey, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	if err := ctxErr("CreateAPIKey", ctx); err != nil {
+		return model.APIKey{}, err
+	}
+	if _, taken := s.byHash[key.Hash]; taken {
+		return model.APIKey{}, &Error{Op: "CreateAPIKey", Kind: &ConflictError{Field: "hash"}}
+	}
+
+	key.ID = s.nextID
+	key.CreatedAt = time.Now()
+	s.keys[key.ID] = key
+	s.byHash[key.Hash] = key.ID
+	s.nextID++
+	return key, nil
+}
+
+// GetAPIKey retrieves the key with the ID.
+func (s *APIKeyStore) GetAPIKey(ctx context.Context, id int) (model.APIKey, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	if err := ctxErr("GetAPIKey", ctx); err != nil {
+		return model.APIKey{}, err
+	}
+	key, ok := s.keys[id]
+	if !ok {
+		return model.APIKey{}, apiKeyNotFound("GetAPIKey", id)
+	}
+	return key, nil
+}
+
+// GetAPIKeyByHash retrieves the key with the hash.
+func (s *APIKeyStore) GetAPIKeyByHash(ctx context.Context, hash string) (model.APIKey, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	if err := ctxErr("GetAPIKeyByHash", ctx); err != nil {
+		return model.APIKey{}, err
+	}
+	id, ok := s.byHash[hash]
+	if !ok {
+		return model.APIKey{}, &Error{Op: "GetAPIKeyByHash", Kind: ErrAPIKeyNotFound}
+	}
+	return s.keys[id], nil
+}
+
+// ListAPIKeys retrieves every key in ID order.
+func (s *APIKeyStore) ListAPIKeys(ctx context.Context) ([]model.APIKey, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	if err := ctxErr("ListAPIKeys", ctx); err != nil {
+		return nil, err
+	}
+	keys := make([]model.APIKey, 0, len(s.keys))
+	for _, key := range s.keys {
+		keys = append(keys, key)
+	}
+	slices.SortFunc(keys, func(a, b model.APIKey) int { return a.ID - b.ID })
+	return keys, nil
+}
+
+// RotateAPIKey replaces the secret of a key. The old secret stops working at once.
+func (s *APIKeyStore) RotateAPIKey(ctx context.Context, id int, prefix, hash string) (model.APIKey, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	if err := ctxErr("RotateAPIKey", ctx); err != nil {
+		return model.APIKey{}, err
+	}
+	key, ok := s.keys[id]
+	if !ok {
+		return model.APIKey{}, apiKeyNotFound("RotateAPIKey", id)
+	}
+	if key.RevokedAt != nil {
+		return model.APIKey{}, &Error{Op: "RotateAPIKey", Kind: ErrAPIKeyRevoked, Err: fmt.Errorf("id %d", id)}
+	}
+
+	now := time.Now()
+	delete(s.byHash, key.Hash)
+	key.Prefix, key.Hash, key.RotatedAt = prefix, hash, &now
+	s.keys[id] = key
+	s.byHash[hash] = id
+	return key, nil
+}
+
+// RevokeAPIKey disables a key. Revoking a revoked key returns it unchanged.
+func (s *APIKeyStore) RevokeAPIKey(ctx context.Context, id int) (model.APIKey, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	if err := ctxErr("RevokeAPIKey", ctx); err != nil {
+		return model.APIKey{}, err
+	}
+	key, ok := s.keys[id]
+	if !ok {
+		return model.APIKey{}, apiKeyNotFound("RevokeAPIKey", id)
+	}
+	if key.RevokedAt == nil {
+		now := time.Now()
+		key.RevokedAt = &now
+		s.keys[id] = key
+	}
+	return key, nil
+}
+
+// PostgresAPIKeyStore is the implementation of APIKeyStoreInterface using
+// GORM, sharing the connection pool of a PostgresUserStore.
+type PostgresAPIKeyStore struct {
+	db *gorm.DB // GORM database connection.
+}
+
+// APIKeys returns the API key store in the same database as the users.
+// Its table is created by migration 0004.
+func (s *PostgresUserStore) APIKeys() *PostgresAPIKeyStore {
+	return &PostgresAPIKeyStore{db: s.db}
+}
+
+// CreateAPIKey inserts a new key.
+func (s *PostgresAPIKeyStore) CreateAPIKey(ctx context.Context, key model.APIKey) (model.APIKey, error) {
+	key.CreatedAt = time.Now()
+	if err := s.db.WithContext(ctx).Create(&key).Error; err != nil {
+		return model.APIKey{}, translateError("CreateAPIKey", err)
+	}
+	return key, nil
+}
+
+// GetAPIKey retrieves the key with the ID.
+func (s *PostgresAPIKeyStore) GetAPIKey(ctx context.Context, id int) (model.APIKey, error) {
+	var key model.APIKey
+	if err := s.db.WithContext(ctx).First(&key, id).Error; err != nil {
+		return model.APIKey{}, apiKeyError("GetAPIKey", err)
+	}
+	return key, nil
+}
+
+// GetAPIKeyByHash retrieves the key with the hash through its unique index.
+func (s *PostgresAPIKeyStore) GetAPIKeyByHash(ctx context.Context, hash string) (model.APIKey, error) {
+	var key model.APIKey
+	if err := s.db.WithContext(ctx).Where("hash = ?", hash).Take(&key).Error; err != nil {
+		return model.APIKey{}, apiKeyError("GetAPIKeyByHash", err)
+	}
+	return key, nil
+}
+
+// ListAPIKeys retrieves every key in ID order.
+func (s *PostgresAPIKeyStore) ListAPIKeys(ctx context.Context) ([]model.APIKey, error) {
+	var keys []model.APIKey
+	if err := s.db.WithContext(ctx).Order("id").Find(&keys).Error; err != nil {
+		return nil, translateError("ListAPIKeys", err)
+	}
+	return keys, nil
+}
+
+// RotateAPIKey replaces the secret of a key. The row lock keeps a concurrent
+// revocation from being overwritten.
+func (s *PostgresAPIKeyStore) RotateAPIKey(ctx context.Context, id int, prefix, hash string) (model.APIKey, error) {
+	var key model.APIKey
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&key, id).Error; err != nil {
+			return err
+		}
+		if key.RevokedAt != nil {
+			return &Error{Op: "RotateAPIKey", Kind: ErrAPIKeyRevoked, Err: fmt.Errorf("id %d", id)}
+		}
+		now := time.Now()
+		key.Prefix, key.Hash, key.RotatedAt = prefix, hash, &now
+		return tx.Save(&key).Error
+	})
+	if err != nil {
+		return model.APIKey{}, apiKeyError("RotateAPIKey", err)
+	}
+	return key, nil
+}
+
+// RevokeAPIKey disables a key, keeping the time of the first revocation.
+func (s *PostgresAPIKeyStore) RevokeAPIKey(ctx context.Context, id int) (model.APIKey, error) {
+	var key model.APIKey
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&key, id).Error; err != nil {
+			return err
+		}
+		if key.RevokedAt != nil {
+			return nil
+		}
+		now := time.Now()
+		key.RevokedAt = &now
+		return tx.Save(&key).Error
+	})
+	if err != nil {
+		return model.APIKey{}, apiKeyError("RevokeAPIKey", err)
+	}
+	return key, nil
+}
+
+// apiKeyError translates an error like translateError, reporting a missing
+// row as ErrAPIKeyNotFound rather than a missing user.
+func apiKeyError(op string, err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &Error{Op: op, Kind: ErrAPIKeyNotFound, Err: err}
+	}
+	return translateError(op, err)
+}
@@ -0,0 +1,106 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Sentinel errors returned by every UserStoreInterface implementation.
+// Callers should test for them with errors.Is rather than comparing messages.
+var (
+	ErrNotFound    = errors.New("user not found")    // The requested user does not exist.
+	ErrConflict    = errors.New("conflict")          // The write conflicts with existing data.
+	ErrUnavailable = errors.New("store unavailable") // The backing store could not be reached.
+	ErrValidation  = errors.New("invalid user data") // The input was rejected by the store.
+
+	// ErrVersionMismatch is returned by conditional writes when the stored
+	// user's version differs from the expected one.
+	ErrVersionMismatch = errors.New("user version mismatch")
+)
+
+// Error records the store operation that failed along with the kind of
+// failure (one of the sentinel errors above) and the underlying cause.
+// errors.Is matches both the kind and the cause.
+type Error struct {
+	Op   string // Store method that failed, e.g. "GetUser".
+	Kind error  // One of the sentinel errors, or nil if unclassified.
+	Err  error  // Underlying error from the driver, if any.
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	switch {
+	case e.Kind != nil && e.Err != nil:
+		return fmt.Sprintf("%s: %v: %v", e.Op, e.Kind, e.Err)
+	case e.Kind != nil:
+		return fmt.Sprintf("%s: %v", e.Op, e.Kind)
+	default:
+		return fmt.Sprintf("%s: %v", e.Op, e.Err)
+	}
+}
+
+// Unwrap exposes both the kind and the cause to errors.Is and errors.As.
+func (e *Error) Unwrap() []error {
+	var errs []error
+	if e.Kind != nil {
+		errs = append(errs, e.Kind)
+	}
+	if e.Err != nil {
+		errs = append(errs, e.Err)
+	}
+	return errs
+}
+
+// ValidationError reports a field value the store refused to accept.
+// It matches ErrValidation with errors.Is.
+type ValidationError struct {
+	Field  string // Name of the offending field or parameter.
+	Reason string // Human-readable explanation.
+}
+
+// Error implements the error interface.
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid %s: %s", e.Field, e.Reason)
+}
+
+// Is reports whether the target is ErrValidation.
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrValidation
+}
+
+// ConflictError reports a write that collides with existing data, such as a
+// duplicate value in a unique column. It matches ErrConflict with errors.Is.
+type ConflictError struct {
+	Field      string // Name of the conflicting field, if known.
+	Value      string // Conflicting value, if known.
+	ExistingID int    // ID of the user that already holds the value, if known.
+}
+
+// Error implements the error interface.
+func (e *ConflictError) Error() string {
+	if e.Field == "" {
+		return ErrConflict.Error()
+	}
+	return fmt.Sprintf("%s %q already exists", e.Field, e.Value)
+}
+
+// Is reports whether the target is ErrConflict.
+func (e *ConflictError) Is(target error) bool {
+	return target == ErrConflict
+}
+
+// emailTaken returns the error reported when another user already has the email.
+func emailTaken(op, email string, existingID int) error {
+	return &Error{Op: op, Kind: &ConflictError{Field: "email", Value: email, ExistingID: existingID}}
+}
+
+// versionMismatch returns the error reported when a conditional write finds
+// the user at a different version than expected.
+func versionMismatch(op string, id, current int) error {
+	return &Error{Op: op, Kind: ErrVersionMismatch, Err: fmt.Errorf("id %d is at version %d", id, current)}
+}
+
+// notFound returns the error reported when the user with the given ID does not exist.
+func notFound(op string, id int) error {
+	return &Error{Op: op, Kind: ErrNotFound, Err: fmt.Errorf("id %d", id)}
+}
@@ -0,0 +1,177 @@
+package store
+
+import (
+	"Curd/model"
+	"encoding/base64"
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+// Default and maximum page sizes for ListUsers.
+const (
+	DefaultListLimit = 50
+	MaxListLimit     = 500
+)
+
+// ErrInvalidCursor is returned when a pagination cursor cannot be decoded
+// or was issued for a different sort order. It matches ErrValidation.
+var ErrInvalidCursor error = &ValidationError{Field: "cursor", Reason: "malformed or issued for a different sort order"}
+
+// SortField identifies the column users are ordered by when listing.
+type SortField string
+
+const (
+	SortByID    SortField = "id"    // Order by user ID (default).
+	SortByName  SortField = "name"  // Order by name, ties broken by ID.
+	SortByEmail SortField = "email" // Order by email, ties broken by ID.
+)
+
+// Valid reports whether the sort field is one of the supported columns.
+func (f SortField) Valid() bool {
+	switch f {
+	case SortByID, SortByName, SortByEmail:
+		return true
+	}
+	return false
+}
+
+// ListOptions controls pagination, ordering and filtering for ListUsers.
+type ListOptions struct {
+	Limit  int       // Maximum number of users to return (defaults to DefaultListLimit).
+	Cursor string    // Opaque cursor returned as NextCursor by a previous call.
+	Sort   SortField // Column to order by (defaults to SortByID).
+	Desc   bool      // Order descending instead of ascending.
+
+	NamePrefix  string // Only include users whose name starts with this prefix.
+	EmailDomain string // Only include users whose email is at this domain (case-insensitive).
+	MinID       int    // Only include users with ID >= MinID (0 means unbounded).
+	MaxID       int    // Only include users with ID <= MaxID (0 means unbounded).
+}
+
+// ListResult is a single page of users returned by ListUsers.
+type ListResult struct {
+	Users      []model.User // Users on this page, in the requested order.
+	NextCursor string       // Cursor for the next page, empty when there are no more users.
+}
+
+// cursor is the decoded form of ListResult.NextCursor.
+// It records the sort key of the last user on the page so the next page can
+// resume strictly after it (keyset pagination).
+type cursor struct {
+	Sort SortField `json:"s"`
+	Desc bool      `json:"d,omitempty"`
+	Key  string    `json:"k,omitempty"`
+	ID   int       `json:"id"`
+}
+
+// normalize fills in defaults and clamps the limit.
+func (o ListOptions) normalize() ListOptions {
+	if o.Limit <= 0 {
+		o.Limit = DefaultListLimit
+	}
+	if o.Limit > MaxListLimit {
+		o.Limit = MaxListLimit
+	}
+	if o.Sort == "" {
+		o.Sort = SortByID
+	}
+	o.EmailDomain = strings.ToLower(strings.TrimPrefix(o.EmailDomain, "@"))
+	return o
+}
+
+// decodeCursor parses the options' cursor, returning nil when there is none.
+func (o ListOptions) decodeCursor() (*cursor, error) {
+	if o.Cursor == "" {
+		return nil, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(o.Cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c cursor
+	if err := json.Unmarshal(raw, &c); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	// A cursor is only meaningful for the ordering it was issued under.
+	if c.Sort != o.Sort || c.Desc != o.Desc {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+// encodeCursor builds the cursor pointing just after the given user.
+func (o ListOptions) encodeCursor(last model.User) string {
+	c := cursor{Sort: o.Sort, Desc: o.Desc, Key: sortKey(last, o.Sort), ID: last.ID}
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// sortKey returns the value of the sort column for a user.
+func sortKey(user model.User, field SortField) string {
+	switch field {
+	case SortByName:
+		return user.Name
+	case SortByEmail:
+		return user.Email
+	}
+	return ""
+}
+
+// matches reports whether a user passes the options' filters.
+func (o ListOptions) matches(user model.User) bool {
+	if o.NamePrefix != "" && !strings.HasPrefix(user.Name, o.NamePrefix) {
+		return false
+	}
+	if o.EmailDomain != "" && !strings.HasSuffix(strings.ToLower(user.Email), "@"+o.EmailDomain) {
+		return false
+	}
+	if o.MinID > 0 && user.ID < o.MinID {
+		return false
+	}
+	if o.MaxID > 0 && user.ID > o.MaxID {
+		return false
+	}
+	return true
+}
+
+// less orders two users by the sort column, falling back to ID.
+func (o ListOptions) less(a, b model.User) bool {
+	ka, kb := sortKey(a, o.Sort), sortKey(b, o.Sort)
+	if ka != kb {
+		return (ka < kb) != o.Desc
+	}
+	return (a.ID < b.ID) != o.Desc
+}
+
+// after reports whether a user sorts strictly after the cursor position.
+func (o ListOptions) after(user model.User, c *cursor) bool {
+	return o.less(model.User{ID: c.ID, Name: c.Key, Email: c.Key}, user)
+}
+
+// PaginateUsers applies ListOptions to an in-memory slice of users.
+// It is used by map-backed stores that cannot push the query down to a database.
+func PaginateUsers(users []model.User, opts ListOptions) (ListResult, error) {
+	opts = opts.normalize()
+	c, err := opts.decodeCursor()
+	if err != nil {
+		return ListResult{}, err
+	}
+
+	// Filter, then order the remaining users.
+	page := make([]model.User, 0, len(users))
+	for _, user := range users {
+		if opts.matches(user) && (c == nil || opts.after(user, c)) {
+			page = append(page, user)
+		}
+	}
+	sort.Slice(page, func(i, j int) bool { return opts.less(page[i], page[j]) })
+
+	// Trim to the limit and emit a cursor if more users remain.
+	result := ListResult{Users: page}
+	if len(page) > opts.Limit {
+		result.Users = page[:opts.Limit]
+		result.NextCursor = opts.encodeCursor(result.Users[opts.Limit-1])
+	}
+	return result, nil
+}
@@ -0,0 +1,243 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// migrationLockID is the key of the Postgres advisory lock held while
+// migrating, so replicas starting at the same time apply each migration once.
+const migrationLockID = 7_310_401_350
+
+//go:embed migrations/*.sql
+var embeddedMigrations embed.FS
+
+// Migration is one versioned schema change with the SQL to apply and revert it.
+type Migration struct {
+	Version int    // Position in the migration history; applied in ascending order.
+	Name    string // Description taken from the file name.
+	Up      string // SQL applying the change.
+	Down    string // SQL reverting the change.
+}
+
+// MigrationStatus reports whether a migration has been applied.
+type MigrationStatus struct {
+	Migration
+	AppliedAt *time.Time // Nil while the migration is pending.
+}
+
+// LoadMigrations reads every "<version>_<name>.up.sql" file in the root of
+// fsys along with its "<version>_<name>.down.sql" counterpart, which is
+// required, and returns them ordered by version.
+func LoadMigrations(fsys fs.FS) ([]Migration, error) {
+	files, err := fs.Glob(fsys, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, file := range files {
+		base, direction, ok := strings.Cut(strings.TrimSuffix(file, ".sql"), ".")
+		prefix, name, found := strings.Cut(base, "_")
+		version, err := strconv.Atoi(prefix)
+		if !ok || !found || err != nil || version <= 0 || (direction != "up" && direction != "down") {
+			return nil, fmt.Errorf("migration %s: name must be <version>_<name>.(up|down).sql", file)
+		}
+		data, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		}
+		if m.Name != name {
+			return nil, fmt.Errorf("migration %s: version %d is already used by %q", file, version, m.Name)
+		}
+		if direction == "up" {
+			m.Up = string(data)
+		} else {
+			m.Down = string(data)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s: both up and down scripts are required", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// DefaultMigrations returns the migrations embedded in the binary.
+// They are parsed once, on first use.
+var DefaultMigrations = sync.OnceValue(func() []Migration {
+	sub, err := fs.Sub(embeddedMigrations, "migrations")
+	if err != nil {
+		panic(err)
+	}
+	migrations, err := LoadMigrations(sub)
+	if err != nil {
+		panic(err) // The embedded migrations are fixed at build time.
+	}
+	return migrations
+})
+
+// Migrator applies and reverts migrations, recording them in the
+// schema_migrations table. Each migration runs in its own transaction.
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+// Migrator returns a migrator for the store's database using the embedded migrations.
+func (s *PostgresUserStore) Migrator() (*Migrator, error) {
+	db, err := s.db.DB()
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: DefaultMigrations()}, nil
+}
+
+// Up applies every pending migration in version order and returns the ones applied.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var applied []Migration
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		done, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, mig := range m.migrations {
+			if _, ok := done[mig.Version]; ok {
+				continue
+			}
+			if err := runMigration(ctx, conn, mig.Up,
+				"INSERT INTO schema_migrations (version, name) VALUES ($1, $2)", mig.Version, mig.Name); err != nil {
+				return fmt.Errorf("apply migration %d_%s: %w", mig.Version, mig.Name, err)
+			}
+			applied = append(applied, mig)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down reverts up to steps applied migrations, newest first, and returns the ones reverted.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var reverted []Migration
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		done, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for i := len(m.migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+			mig := m.migrations[i]
+			if _, ok := done[mig.Version]; !ok {
+				continue
+			}
+			if err := runMigration(ctx, conn, mig.Down,
+				"DELETE FROM schema_migrations WHERE version = $1", mig.Version); err != nil {
+				return fmt.Errorf("revert migration %d_%s: %w", mig.Version, mig.Name, err)
+			}
+			reverted = append(reverted, mig)
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// Status lists every known migration and when it was applied.
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	var statuses []MigrationStatus
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		done, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, mig := range m.migrations {
+			status := MigrationStatus{Migration: mig}
+			if at, ok := done[mig.Version]; ok {
+				status.AppliedAt = &at
+			}
+			statuses = append(statuses, status)
+		}
+		return nil
+	})
+	return statuses, err
+}
+
+// locked runs fn on a dedicated connection holding the migration advisory
+// lock, after making sure the schema_migrations table exists.
+func (m *Migrator) locked(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return translateError("Migrate", err)
+	}
+	defer conn.Close()
+
+	// Session-level lock: it is tied to this connection, not to a transaction.
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", migrationLockID); err != nil {
+		return translateError("Migrate", err)
+	}
+	defer conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", migrationLockID)
+
+	if _, err := conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    bigint PRIMARY KEY,
+		name       text NOT NULL,
+		applied_at timestamptz NOT NULL DEFAULT now()
+	)`); err != nil {
+		return translateError("Migrate", err)
+	}
+	return fn(conn)
+}
+
+// appliedVersions returns the applied migration versions and when they were applied.
+func appliedVersions(ctx context.Context, conn *sql.Conn) (map[int]time.Time, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	done := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var at time.Time
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+		done[version] = at
+	}
+	return done, rows.Err()
+}
+
+// runMigration executes a migration script and the bookkeeping statement
+// that records it in one transaction, so a failed script leaves no trace.
+func runMigration(ctx context.Context, conn *sql.Conn, script, record string, args ...any) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // No-op once committed.
+
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, record, args...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
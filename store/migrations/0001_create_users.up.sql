@@ -0,0 +1,19 @@
+-- Users, as previously created by GORM's AutoMigrate. IF NOT EXISTS lets
+-- databases that were auto-migrated adopt the migration history; those only
+-- have id, name and email, so the later columns are added separately below.
+CREATE TABLE IF NOT EXISTS users (
+    id      bigserial PRIMARY KEY,
+    name    text,
+    email   text,
+    locale  text,
+    version bigint NOT NULL DEFAULT 1
+);
+
+-- Bring auto-migrated tables up to the shape created above.
+-- Existing rows start their version history at 1.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS locale text;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS version bigint NOT NULL DEFAULT 1;
+
+-- Composite indexes backing keyset pagination by name and by email.
+CREATE INDEX IF NOT EXISTS idx_users_name_id ON users (name, id);
+CREATE INDEX IF NOT EXISTS idx_users_email_id ON users (email, id);
@@ -0,0 +1,12 @@
+-- Transactional outbox of user lifecycle events, published by OutboxRelay.
+CREATE TABLE IF NOT EXISTS outbox_events (
+    id           bigserial PRIMARY KEY,
+    event_type   text NOT NULL,
+    payload      jsonb NOT NULL,
+    created_at   timestamptz NOT NULL,
+    delivered_at timestamptz,
+    attempts     bigint NOT NULL DEFAULT 0,
+    last_error   text
+);
+
+CREATE INDEX IF NOT EXISTS idx_outbox_events_delivered_at ON outbox_events (delivered_at);
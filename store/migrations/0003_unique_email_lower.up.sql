@@ -0,0 +1,3 @@
+-- Emails are unique regardless of case. Fails if duplicates already exist;
+-- resolve them by hand and rerun the migration.
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email_lower ON users (lower(email));
@@ -0,0 +1,14 @@
+-- API keys of machine clients. Only the SHA-256 of each key is stored.
+CREATE TABLE IF NOT EXISTS api_keys (
+    id         bigserial PRIMARY KEY,
+    name       text NOT NULL,
+    prefix     text NOT NULL,
+    hash       text NOT NULL,
+    scopes     jsonb NOT NULL DEFAULT '[]',
+    expires_at timestamptz,
+    created_at timestamptz NOT NULL,
+    rotated_at timestamptz,
+    revoked_at timestamptz
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_api_keys_hash ON api_keys (hash);
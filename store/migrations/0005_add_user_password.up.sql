@@ -0,0 +1,2 @@
+-- argon2id password hashes, in PHC string format. Empty until a password is set.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS password_hash text NOT NULL DEFAULT '';
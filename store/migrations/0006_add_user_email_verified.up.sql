@@ -0,0 +1,3 @@
+-- Whether the user confirmed their email through a verification link.
+-- Reset whenever the email changes.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verified boolean NOT NULL DEFAULT false;
@@ -0,0 +1,3 @@
+-- Relays claim a batch of events for a lease, commit, and only then deliver
+-- them. A claim that outlives its lease, e.g. after a crash, is taken over.
+ALTER TABLE outbox_events ADD COLUMN IF NOT EXISTS claimed_until timestamptz;
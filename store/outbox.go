@@ -0,0 +1,215 @@
+package store
+
+import (
+	"Curd/logging"
+	"Curd/model"
+	"Curd/notification"
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// OutboxEvent is a user lifecycle event recorded in the same transaction as
+// the write that caused it. OutboxRelay publishes pending rows to the
+// notifiers, so side effects happen at least once for every committed change.
+type OutboxEvent struct {
+	ID           int64                  `gorm:"primaryKey;autoIncrement"`
+	EventType    notification.EventType `gorm:"not null"`
+	Payload      []byte                 `gorm:"type:jsonb;not null"` // JSON-encoded notification.Event.
+	CreatedAt    time.Time              `gorm:"not null"`
+	DeliveredAt  *time.Time             `gorm:"index"` // Nil until the relay has published the event.
+	ClaimedUntil *time.Time             // Until when a relay is delivering the event.
+	Attempts     int                    `gorm:"not null;default:0"`
+	LastError    string
+}
+
+// TableName sets the table used for outbox events.
+func (OutboxEvent) TableName() string {
+	return "outbox_events"
+}
+
+// enqueueOutbox records an event for the given user inside the transaction tx.
+func enqueueOutbox(tx *gorm.DB, eventType notification.EventType, user model.User) error {
+	payload, err := json.Marshal(notification.Event{Type: eventType, User: user})
+	if err != nil {
+		return err
+	}
+	return tx.Create(&OutboxEvent{EventType: eventType, Payload: payload}).Error
+}
+
+// OutboxRelay publishes pending outbox events to a notifier and marks them delivered.
+// Several replicas may run a relay against the same database: each batch is
+// claimed for a lease, renewed for as long as the batch is in flight, so an
+// event is handled by one relay at a time however long its retries take. A
+// claim left behind by a relay that crashed is taken over once it expires.
+type OutboxRelay struct {
+	db        *gorm.DB
+	notifier  notification.Notifier
+	interval  time.Duration // Delay between polls when the outbox is empty.
+	batchSize int           // Maximum number of events claimed per poll.
+	lease     time.Duration // How long a claim lasts unless renewed; renewed every third of it while in flight.
+}
+
+// NewOutboxRelay returns a relay that publishes the store's outbox events to
+// the notifier, polling every interval. An event is only marked delivered
+// once Notify returns nil, so the notifier must not return before the event
+// is safe, e.g. notification.Queue.Synchronous rather than the Queue itself.
+func (s *PostgresUserStore) NewOutboxRelay(notifier notification.Notifier, interval time.Duration) *OutboxRelay {
+	return &OutboxRelay{db: s.db, notifier: notifier, interval: interval, batchSize: 100, lease: time.Minute}
+}
+
+// Run publishes events until ctx is cancelled.
+func (r *OutboxRelay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		// Keep draining without waiting while full batches are coming back.
+		delivered, err := r.RelayPending(ctx)
+		if err != nil && ctx.Err() == nil {
+			logging.FromContext(ctx).Error("outbox relay failed", "error", err)
+		}
+		if delivered == r.batchSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RelayPending publishes one batch of undelivered events and returns how
+// many were delivered. The batch is claimed in a short transaction that is
+// committed before any event is sent, so no row lock is held while the
+// notifier works. Events whose delivery fails stay pending and are retried
+// on the next poll.
+func (r *OutboxRelay) RelayPending(ctx context.Context) (int, error) {
+	events, err := r.claim(ctx)
+	if err != nil {
+		return 0, translateError("RelayPending", err)
+	}
+
+	// Deliver the batch concurrently; each event is settled on its own.
+	// The claims are renewed meanwhile, so a batch held up by retries against
+	// a failing provider is not claimed and sent again by another relay.
+	stopRenewing := r.renew(ctx, events)
+	results := make([]error, len(events))
+	var wg sync.WaitGroup
+	for i, row := range events {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = r.publish(ctx, row)
+		}()
+	}
+	wg.Wait()
+	stopRenewing()
+
+	// Record the outcomes, releasing the claims. Without a context, the
+	// outcome of a delivery that finished during shutdown is still saved.
+	db := r.db.WithContext(context.WithoutCancel(ctx))
+	delivered := 0
+	var errs []error
+	for i, row := range events {
+		updates := map[string]any{"claimed_until": nil}
+		if results[i] != nil {
+			updates["last_error"] = results[i].Error() // Leave it pending for the next poll.
+		} else {
+			updates["delivered_at"] = time.Now().UTC()
+			updates["last_error"] = ""
+			delivered++
+		}
+		if err := db.Model(&OutboxEvent{}).Where("id = ?", row.ID).Updates(updates).Error; err != nil {
+			errs = append(errs, err) // The claim expires, so the event is retried anyway.
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return delivered, translateError("RelayPending", err)
+	}
+	return delivered, nil
+}
+
+// claim takes the oldest pending events that no other relay holds a live
+// claim on, and claims them for the lease. FOR UPDATE SKIP LOCKED keeps
+// relays polling at the same moment from claiming the same rows.
+func (r *OutboxRelay) claim(ctx context.Context) ([]OutboxEvent, error) {
+	var events []OutboxEvent
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now().UTC()
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("delivered_at IS NULL AND (claimed_until IS NULL OR claimed_until < ?)", now).
+			Order("id").
+			Limit(r.batchSize).
+			Find(&events).Error; err != nil {
+			return err
+		}
+		if len(events) == 0 {
+			return nil
+		}
+
+		ids := make([]int64, len(events))
+		for i, row := range events {
+			ids[i] = row.ID
+		}
+		return tx.Model(&OutboxEvent{}).Where("id IN ?", ids).Updates(map[string]any{
+			"claimed_until": now.Add(r.lease),
+			"attempts":      gorm.Expr("attempts + 1"),
+		}).Error
+	})
+	return events, err
+}
+
+// renew extends the claim on the events by a lease every third of a lease,
+// until the returned function is called. A relay that crashes stops renewing,
+// so its claims still expire.
+func (r *OutboxRelay) renew(ctx context.Context, events []OutboxEvent) (stop func()) {
+	if len(events) == 0 {
+		return func() {}
+	}
+	ids := make([]int64, len(events))
+	for i, row := range events {
+		ids[i] = row.ID
+	}
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(r.lease / 3)
+		defer ticker.Stop()
+		db := r.db.WithContext(context.WithoutCancel(ctx)) // Deliveries may outlive a cancelled ctx.
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			err := db.Model(&OutboxEvent{}).
+				Where("id IN ? AND delivered_at IS NULL", ids).
+				Update("claimed_until", time.Now().UTC().Add(r.lease)).Error
+			if err != nil {
+				logging.FromContext(ctx).Warn("renewing outbox claims failed", "events", len(ids), "error", err)
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped // No renewal may land after the claims are released.
+	}
+}
+
+// publish decodes an outbox row and hands it to the notifier.
+func (r *OutboxRelay) publish(ctx context.Context, row OutboxEvent) error {
+	var event notification.Event
+	if err := json.Unmarshal(row.Payload, &event); err != nil {
+		return err
+	}
+	return r.notifier.Notify(ctx, event)
+}
@@ -2,96 +2,357 @@ package store
 
 import (
 	"Curd/model"
+	"Curd/notification"
+	"context"
+	"database/sql/driver"
 	"errors"
+	"fmt"
+	"net"
+	"strings"
 
+	"github.com/jackc/pgx/v5/pgconn"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // PostgresUserStoreInterface defines the methods for interacting with the user store.
 type PostgresUserStoreInterface interface {
-	CreateUser(user model.User) (model.User, error)         // Create a new user in the database.
-	GetUser(id int) (model.User, error)                     // Retrieve a user by their ID.
-	UpdateUser(id int, user model.User) (model.User, error) // Update an existing user's details.
-	DeleteUser(id int) error                                // Delete a user by their ID.
-	GetAllUser() ([]model.User, error)                      // Retrieve all users from the database.
+	CreateUser(ctx context.Context, user model.User) (model.User, error)                              // Create a new user in the database.
+	GetUser(ctx context.Context, id int) (model.User, error)                                          // Retrieve a user by their ID.
+	UpdateUser(ctx context.Context, id int, user model.User, ifVersion int) (model.User, bool, error) // Update an existing user's details, reporting whether the email changed.
+	DeleteUser(ctx context.Context, id int, ifVersion int) error                                      // Delete a user by their ID.
+	GetAllUser(ctx context.Context) ([]model.User, error)                                             // Retrieve all users from the database.
+	ListUsers(ctx context.Context, opts ListOptions) (ListResult, error)                              // Retrieve a filtered, sorted page of users.
+	GetUserByEmail(ctx context.Context, email string) (model.User, error)                             // Retrieve a user by email, ignoring case.
+	SetPassword(ctx context.Context, id int, hash string) error                                       // Replace a user's password hash.
+	VerifyEmail(ctx context.Context, id int, email string) (model.User, error)                        // Mark a user's email as verified if it is still email.
 }
 
+// emailUniqueIndex is the unique index on lower(email), created by migration 0003.
+const emailUniqueIndex = "idx_users_email_lower"
+
 // PostgresUserStore is the implementation of PostgresUserStoreInterface using GORM.
 type PostgresUserStore struct {
 	db *gorm.DB // GORM database connection.
 }
 
 // NewPostgresUserStore initializes a new PostgresUserStore with the given DSN (Data Source Name).
+// It does not change the schema; apply pending migrations with Migrator first.
 func NewPostgresUserStore(dsn string) (*PostgresUserStore, error) {
 	// Open a connection to the PostgreSQL database using GORM.
 	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
 	if err != nil {
-		return nil, err // Return an error if the connection fails.
-	}
-
-	// Auto-migrate the User schema to ensure the database structure matches the model.
-	if err := db.AutoMigrate(&model.User{}); err != nil {
-		return nil, err // Return an error if migration fails.
+		return nil, &Error{Op: "Open", Kind: ErrUnavailable, Err: err} // Return an error if the connection fails.
 	}
 
 	// Return the initialized PostgresUserStore.
 	return &PostgresUserStore{db: db}, nil
 }
 
+// Check pings the database, implementing health.Checker.
+func (s *PostgresUserStore) Check(ctx context.Context) error {
+	db, err := s.db.DB()
+	if err != nil {
+		return err
+	}
+	if err := db.PingContext(ctx); err != nil {
+		return translateError("Ping", err)
+	}
+	return nil
+}
+
+// Close closes the database connection pool. Queries in flight are allowed to finish.
+func (s *PostgresUserStore) Close() error {
+	db, err := s.db.DB()
+	if err != nil {
+		return err
+	}
+	return db.Close()
+}
+
 // CreateUser creates a new user in the database.
-func (s *PostgresUserStore) CreateUser(user model.User) (model.User, error) {
-	// Use GORM to insert the user into the database.
-	if err := s.db.Create(&user).Error; err != nil {
-		return model.User{}, err // Return an error if the operation fails.
+// A user.created outbox event is written in the same transaction.
+func (s *PostgresUserStore) CreateUser(ctx context.Context, user model.User) (model.User, error) {
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Use GORM to insert the user into the database, starting its version history.
+		// The email is unverified until the user follows the emailed link.
+		user.Version = 1
+		user.EmailVerified = false
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		return enqueueOutbox(tx, notification.EventUserCreated, user)
+	})
+	if err != nil {
+		return model.User{}, s.withExistingEmail(ctx, translateError("CreateUser", err), user.Email) // Return a typed error if the operation fails.
 	}
 	return user, nil // Return the created user.
 }
 
 // GetUser retrieves a user by their ID.
-func (s *PostgresUserStore) GetUser(id int) (model.User, error) {
+func (s *PostgresUserStore) GetUser(ctx context.Context, id int) (model.User, error) {
 	var user model.User
 	// Use GORM to find the user by ID.
-	if err := s.db.First(&user, id).Error; err != nil {
-		return model.User{}, errors.New("user not found") // Return an error if the user is not found.
+	if err := s.db.WithContext(ctx).First(&user, id).Error; err != nil {
+		return model.User{}, translateError("GetUser", err) // Return ErrNotFound, or ErrUnavailable on outages.
 	}
 	return user, nil // Return the retrieved user.
 }
 
 // GetAllUser retrieves all users from the database.
-func (s *PostgresUserStore) GetAllUser() ([]model.User, error) {
+func (s *PostgresUserStore) GetAllUser(ctx context.Context) ([]model.User, error) {
 	var users []model.User
 	// Use GORM to retrieve all users.
-	if err := s.db.Find(&users).Error; err != nil {
-		return []model.User{}, errors.New("user not found") // Return an error if the operation fails.
+	if err := s.db.WithContext(ctx).Find(&users).Error; err != nil {
+		return []model.User{}, translateError("GetAllUser", err) // Return a typed error if the operation fails.
 	}
 	return users, nil // Return the list of users.
 }
 
+// ListUsers retrieves a page of users using a keyset query.
+// The (sort column, id) pair is backed by a composite index, so each page is
+// an index range scan regardless of how deep into the result set it is.
+func (s *PostgresUserStore) ListUsers(ctx context.Context, opts ListOptions) (ListResult, error) {
+	opts = opts.normalize()
+	c, err := opts.decodeCursor()
+	if err != nil {
+		return ListResult{}, err
+	}
+
+	// Apply the filters.
+	query := s.db.WithContext(ctx).Model(&model.User{})
+	if opts.NamePrefix != "" {
+		query = query.Where("name LIKE ?", escapeLike(opts.NamePrefix)+"%")
+	}
+	if opts.EmailDomain != "" {
+		query = query.Where("lower(email) LIKE ?", "%@"+escapeLike(opts.EmailDomain))
+	}
+	if opts.MinID > 0 {
+		query = query.Where("id >= ?", opts.MinID)
+	}
+	if opts.MaxID > 0 {
+		query = query.Where("id <= ?", opts.MaxID)
+	}
+
+	// Resume strictly after the cursor position.
+	op, dir := ">", "ASC"
+	if opts.Desc {
+		op, dir = "<", "DESC"
+	}
+	if c != nil {
+		if opts.Sort == SortByID {
+			query = query.Where("id "+op+" ?", c.ID)
+		} else {
+			query = query.Where(fmt.Sprintf("(%s, id) %s (?, ?)", opts.Sort, op), c.Key, c.ID)
+		}
+	}
+	if opts.Sort != SortByID {
+		query = query.Order(fmt.Sprintf("%s %s", opts.Sort, dir))
+	}
+	query = query.Order("id " + dir)
+
+	// Fetch one extra row to find out whether another page exists.
+	var users []model.User
+	if err := query.Limit(opts.Limit + 1).Find(&users).Error; err != nil {
+		return ListResult{}, translateError("ListUsers", err)
+	}
+	result := ListResult{Users: users}
+	if len(users) > opts.Limit {
+		result.Users = users[:opts.Limit]
+		result.NextCursor = opts.encodeCursor(result.Users[opts.Limit-1])
+	}
+	return result, nil
+}
+
+// escapeLike escapes the LIKE wildcard characters in a user-supplied pattern.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
 // UpdateUser updates an existing user's details.
-func (s *PostgresUserStore) UpdateUser(id int, updatedUser model.User) (model.User, error) {
+// A user.updated outbox event is written in the same transaction.
+// The row lock makes the version check, the email comparison and the write atomic.
+func (s *PostgresUserStore) UpdateUser(ctx context.Context, id int, updatedUser model.User, ifVersion int) (model.User, bool, error) {
 	var user model.User
-	// Use GORM to find the user by ID.
-	if err := s.db.First(&user, id).Error; err != nil {
-		return model.User{}, errors.New("user not found") // Return an error if the user is not found.
-	}
+	var emailChanged bool
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Use GORM to find and lock the user by ID.
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, id).Error; err != nil {
+			return err
+		}
+		if ifVersion != 0 && user.Version != ifVersion {
+			return versionMismatch("UpdateUser", id, user.Version) // Someone else wrote first.
+		}
 
-	// Update the user's fields with the new data.
-	user.Name = updatedUser.Name
-	user.Email = updatedUser.Email
+		// Update the user's fields with the new data and bump the version.
+		// A new email must be verified again.
+		if emailChanged = !strings.EqualFold(user.Email, updatedUser.Email); emailChanged {
+			user.EmailVerified = false
+		}
+		user.Name = updatedUser.Name
+		user.Email = updatedUser.Email
+		user.Locale = updatedUser.Locale
+		user.Version++
 
-	// Save the updated user back to the database.
-	if err := s.db.Save(&user).Error; err != nil {
-		return model.User{}, err // Return an error if the operation fails.
+		// Save the updated user back to the database.
+		if err := tx.Save(&user).Error; err != nil {
+			return err
+		}
+		return enqueueOutbox(tx, notification.EventUserUpdated, user)
+	})
+	if err != nil {
+		return model.User{}, false, s.withExistingEmail(ctx, translateError("UpdateUser", err), updatedUser.Email) // Return ErrNotFound, ErrConflict, or ErrUnavailable on outages.
 	}
-	return user, nil // Return the updated user.
+	return user, emailChanged, nil // Return the updated user.
 }
 
 // DeleteUser deletes a user by their ID.
-func (s *PostgresUserStore) DeleteUser(id int) error {
-	// Use GORM to delete the user by ID.
-	if err := s.db.Delete(&model.User{}, id).Error; err != nil {
-		return errors.New("user not found") // Return an error if the user is not found.
+// A user.deleted outbox event carrying the removed user is written in the same transaction.
+// The row lock makes the version check and the delete atomic.
+func (s *PostgresUserStore) DeleteUser(ctx context.Context, id int, ifVersion int) error {
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Load the user first so the event can describe who was deleted.
+		var user model.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, id).Error; err != nil {
+			return err
+		}
+		if ifVersion != 0 && user.Version != ifVersion {
+			return versionMismatch("DeleteUser", id, user.Version) // Someone else wrote first.
+		}
+
+		// Use GORM to delete the user by ID.
+		if err := tx.Delete(&user).Error; err != nil {
+			return err
+		}
+		return enqueueOutbox(tx, notification.EventUserDeleted, user)
+	})
+	if err != nil {
+		return translateError("DeleteUser", err) // Return ErrNotFound, or ErrUnavailable on outages.
 	}
 	return nil // Return nil if the operation is successful.
 }
+
+// GetUserByEmail retrieves a user by their email through the unique index on lower(email).
+func (s *PostgresUserStore) GetUserByEmail(ctx context.Context, email string) (model.User, error) {
+	var user model.User
+	if err := s.db.WithContext(ctx).Where("lower(email) = lower(?)", email).Take(&user).Error; err != nil {
+		return model.User{}, translateError("GetUserByEmail", err) // Return ErrNotFound, or ErrUnavailable on outages.
+	}
+	return user, nil
+}
+
+// SetPassword replaces the password hash of a user. The version is not
+// bumped and no outbox event is written, since the hash is not part of the
+// user's representation.
+func (s *PostgresUserStore) SetPassword(ctx context.Context, id int, hash string) error {
+	result := s.db.WithContext(ctx).Model(&model.User{}).Where("id = ?", id).Update("password_hash", hash)
+	if result.Error != nil {
+		return translateError("SetPassword", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return notFound("SetPassword", id)
+	}
+	return nil
+}
+
+// VerifyEmail marks the email of a user as verified, provided it is still
+// email; a user who changed it since gets ErrNotFound. No outbox event is
+// written, so verifying does not send a "profile updated" notification.
+func (s *PostgresUserStore) VerifyEmail(ctx context.Context, id int, email string) (model.User, error) {
+	var user model.User
+	result := s.db.WithContext(ctx).Model(&user).Clauses(clause.Returning{}).
+		Where("id = ? AND lower(email) = lower(?)", id, email).
+		Updates(map[string]any{"email_verified": true, "version": gorm.Expr("version + 1")})
+	if result.Error != nil {
+		return model.User{}, translateError("VerifyEmail", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return model.User{}, notFound("VerifyEmail", id)
+	}
+	return user, nil
+}
+
+// withExistingEmail completes an email conflict error with the email and the
+// ID of the user that already holds it, so callers can point at that resource.
+func (s *PostgresUserStore) withExistingEmail(ctx context.Context, err error, email string) error {
+	var conflict *ConflictError
+	if !errors.As(err, &conflict) || conflict.Field != "email" {
+		return err
+	}
+	conflict.Value = email
+	var existing model.User
+	if s.db.WithContext(ctx).Select("id").Where("lower(email) = lower(?)", email).Take(&existing).Error == nil {
+		conflict.ExistingID = existing.ID
+	}
+	return err
+}
+
+// uniqueIndexFields maps the unique indexes created by the migrations, whose
+// names follow no fixed pattern, onto the field they keep unique.
+var uniqueIndexFields = map[string]string{
+	emailUniqueIndex:    "email",
+	"idx_api_keys_hash": "hash",
+}
+
+// conflictField names the field a unique violation is about. Postgres only
+// reports the violated constraint, not its column, so the field is looked up
+// for known indexes and otherwise read from the default constraint names
+// "<table>_pkey" and "<table>_<column>_key". Other names are returned as-is.
+func conflictField(pgErr *pgconn.PgError) string {
+	name := pgErr.ConstraintName
+	if field, ok := uniqueIndexFields[name]; ok {
+		return field
+	}
+	if name == pgErr.TableName+"_pkey" {
+		return "id"
+	}
+	if column, ok := strings.CutPrefix(name, pgErr.TableName+"_"); ok && pgErr.TableName != "" {
+		if column, ok := strings.CutSuffix(column, "_key"); ok {
+			return column
+		}
+	}
+	return name
+}
+
+// translateError maps a GORM or driver error onto the store's typed errors,
+// so callers can tell a missing row from a constraint violation or an outage.
+func translateError(op string, err error) error {
+	// Errors raised by the store itself are already typed.
+	var storeErr *Error
+	if errors.As(err, &storeErr) {
+		return err
+	}
+
+	// Cancellation and deadlines come from the caller, so keep them visible as-is.
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return &Error{Op: op, Err: err}
+	}
+
+	// A missing row is the only error GORM itself reports for lookups.
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &Error{Op: op, Kind: ErrNotFound, Err: err}
+	}
+
+	// Server-side errors carry a SQLSTATE code whose class identifies the failure.
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch {
+		case pgErr.Code == "23505": // unique_violation
+			return &Error{Op: op, Kind: &ConflictError{Field: conflictField(pgErr)}, Err: err}
+		case strings.HasPrefix(pgErr.Code, "22"), strings.HasPrefix(pgErr.Code, "23"): // data exception, integrity constraint violation
+			return &Error{Op: op, Kind: &ValidationError{Field: pgErr.ColumnName, Reason: pgErr.Message}, Err: err}
+		case strings.HasPrefix(pgErr.Code, "08"), strings.HasPrefix(pgErr.Code, "53"), strings.HasPrefix(pgErr.Code, "57"): // connection, resources, operator intervention
+			return &Error{Op: op, Kind: ErrUnavailable, Err: err}
+		}
+		return &Error{Op: op, Err: err}
+	}
+
+	// Anything that never reached the server is a connectivity problem.
+	var connErr *pgconn.ConnectError
+	var netErr net.Error
+	if errors.As(err, &connErr) || errors.As(err, &netErr) || errors.Is(err, driver.ErrBadConn) || pgconn.Timeout(err) {
+		return &Error{Op: op, Kind: ErrUnavailable, Err: err}
+	}
+	return &Error{Op: op, Err: err}
+}
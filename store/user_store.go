@@ -1,99 +1,240 @@
 package store
 
 import (
+	"Curd/logging"
 	"Curd/model"
-	"errors"
-	"log"
+	"context"
+	"strings"
 	"sync"
 )
 
 // UserStoreInterface defines the methods that a UserStore must implement.
+// UpdateUser and DeleteUser are conditional when ifVersion is non-zero: they
+// fail with ErrVersionMismatch unless the stored user has exactly that version.
+// UpdateUser also reports whether the write changed the user's email, decided
+// atomically with it, so callers can ask for the new address to be verified.
 type UserStoreInterface interface {
-	CreateUser(user model.User) (model.User, error)         // Create a new user
-	GetUser(id int) (model.User, error)                     // Retrieve a user by ID
-	UpdateUser(id int, user model.User) (model.User, error) // Update an existing user by ID
-	DeleteUser(id int) error                                // Delete a user by ID
-	GetAllUser() ([]model.User, error)                      // Retrieve all users
+	CreateUser(ctx context.Context, user model.User) (model.User, error)                              // Create a new user
+	GetUser(ctx context.Context, id int) (model.User, error)                                          // Retrieve a user by ID
+	UpdateUser(ctx context.Context, id int, user model.User, ifVersion int) (model.User, bool, error) // Update an existing user by ID, reporting whether the email changed
+	DeleteUser(ctx context.Context, id int, ifVersion int) error                                      // Delete a user by ID
+	GetAllUser(ctx context.Context) ([]model.User, error)                                             // Retrieve all users
+	ListUsers(ctx context.Context, opts ListOptions) (ListResult, error)                              // Retrieve a filtered, sorted page of users
+	GetUserByEmail(ctx context.Context, email string) (model.User, error)                             // Retrieve a user by email, ignoring case
+	SetPassword(ctx context.Context, id int, hash string) error                                       // Replace a user's password hash
+	VerifyEmail(ctx context.Context, id int, email string) (model.User, error)                        // Mark a user's email as verified if it is still email
 }
 
 // UserStore is an in-memory implementation of UserStoreInterface.
 // It uses a map to store users and a mutex for thread-safe operations.
+// A secondary index on the lowercased email keeps emails unique.
 type UserStore struct {
 	sync.Mutex
-	users  map[int]model.User // Map to store users with their ID as the key
-	nextID int                // Counter to generate unique user IDs
+	users   map[int]model.User // Map to store users with their ID as the key
+	byEmail map[string]int     // Map from lowercased email to the ID of the user holding it
+	nextID  int                // Counter to generate unique user IDs
 }
 
 // NewUserStore initializes and returns a new UserStore instance.
 func NewUserStore() (*UserStore, error) {
 	return &UserStore{
-		users:  make(map[int]model.User), // Initialize the user map
-		nextID: 1,                        // Start IDs from 1
+		users:   make(map[int]model.User), // Initialize the user map
+		byEmail: make(map[string]int),     // Initialize the email index
+		nextID:  1,                        // Start IDs from 1
 	}, nil
 }
 
 // CreateUser adds a new user to the store and assigns a unique ID.
-func (s *UserStore) CreateUser(user model.User) (model.User, error) {
+func (s *UserStore) CreateUser(ctx context.Context, user model.User) (model.User, error) {
 	s.Lock()
 	defer s.Unlock()
 
-	// Assign a unique ID to the user
+	if err := ctxErr("CreateUser", ctx); err != nil {
+		return model.User{}, err // Give up if the caller has already gone away
+	}
+
+	// Reject the email if another user already has it, ignoring case
+	if existingID, taken := s.byEmail[strings.ToLower(user.Email)]; taken {
+		return model.User{}, emailTaken("CreateUser", user.Email, existingID)
+	}
+
+	// Assign a unique ID to the user and start its version history
+	// The email is unverified until the user follows the emailed link
 	user.ID = s.nextID
-	s.users[user.ID] = user // Add the user to the map
-	s.nextID++              // Increment the ID counter
+	user.Version = 1
+	user.EmailVerified = false
+	s.users[user.ID] = user                          // Add the user to the map
+	s.byEmail[strings.ToLower(user.Email)] = user.ID // Index the email
+	s.nextID++                                       // Increment the ID counter
 	return user, nil
 }
 
+// Check implements health.Checker. The in-memory store is always available.
+func (s *UserStore) Check(ctx context.Context) error {
+	return nil
+}
+
 // GetUser retrieves a user by their ID.
-func (s *UserStore) GetUser(id int) (model.User, error) {
+func (s *UserStore) GetUser(ctx context.Context, id int) (model.User, error) {
 	s.Lock()
 	defer s.Unlock()
 
-	log.Printf("ID: %v\n", id) // Log the ID being retrieved
+	if err := ctxErr("GetUser", ctx); err != nil {
+		return model.User{}, err // Give up if the caller has already gone away
+	}
+
+	logging.FromContext(ctx).Debug("get user", "user_id", id) // Log the ID being retrieved
 	user, ok := s.users[id]
 	if !ok {
-		return model.User{}, errors.New("user not found") // Return an error if the user doesn't exist
+		return model.User{}, notFound("GetUser", id) // Return an error if the user doesn't exist
 	}
 	return user, nil
 }
 
 // GetAllUser retrieves all users from the store.
-func (s *UserStore) GetAllUser() ([]model.User, error) {
+func (s *UserStore) GetAllUser(ctx context.Context) ([]model.User, error) {
 	s.Lock()
 	defer s.Unlock()
 
-	var users []model.User
+	if err := ctxErr("GetAllUser", ctx); err != nil {
+		return nil, err // Give up if the caller has already gone away
+	}
+
+	users := make([]model.User, 0, len(s.users)) // An empty store yields an empty list, not an error
 	for _, value := range s.users {
 		users = append(users, value) // Collect all users into a slice
 	}
-	if len(users) == 0 {
-		return nil, errors.New("no users found") // Return an error if no users exist
-	}
 	return users, nil
 }
 
+// ListUsers retrieves a page of users matching the given options.
+func (s *UserStore) ListUsers(ctx context.Context, opts ListOptions) (ListResult, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	if err := ctxErr("ListUsers", ctx); err != nil {
+		return ListResult{}, err // Give up if the caller has already gone away
+	}
+
+	users := make([]model.User, 0, len(s.users))
+	for _, value := range s.users {
+		users = append(users, value) // Collect all users, PaginateUsers filters and orders them
+	}
+	return PaginateUsers(users, opts)
+}
+
 // UpdateUser updates an existing user's details by their ID.
-func (s *UserStore) UpdateUser(id int, user model.User) (model.User, error) {
+func (s *UserStore) UpdateUser(ctx context.Context, id int, user model.User, ifVersion int) (model.User, bool, error) {
 	s.Lock()
 	defer s.Unlock()
 
-	_, ok := s.users[id]
+	if err := ctxErr("UpdateUser", ctx); err != nil {
+		return model.User{}, false, err // Give up if the caller has already gone away
+	}
+
+	existing, ok := s.users[id]
 	if !ok {
-		return model.User{}, errors.New("user not found") // Return an error if the user doesn't exist
+		return model.User{}, false, notFound("UpdateUser", id) // Return an error if the user doesn't exist
+	}
+	if ifVersion != 0 && existing.Version != ifVersion {
+		return model.User{}, false, versionMismatch("UpdateUser", id, existing.Version) // Someone else wrote first
 	}
-	user.ID = id       // Ensure the ID remains unchanged
+	if holder, taken := s.byEmail[strings.ToLower(user.Email)]; taken && holder != id {
+		return model.User{}, false, emailTaken("UpdateUser", user.Email, holder) // Another user has the new email
+	}
+	delete(s.byEmail, strings.ToLower(existing.Email)) // Re-index the email in case it changed
+	s.byEmail[strings.ToLower(user.Email)] = id
+	user.ID = id                              // Ensure the ID remains unchanged
+	user.Version = existing.Version + 1       // Bump the version on every write
+	user.PasswordHash = existing.PasswordHash // Passwords only change through SetPassword
+
+	// A new email must be verified again
+	emailChanged := !strings.EqualFold(existing.Email, user.Email)
+	user.EmailVerified = existing.EmailVerified && !emailChanged
 	s.users[id] = user // Update the user in the map
+	return user, emailChanged, nil
+}
+
+// VerifyEmail marks the email of a user as verified, provided it is still
+// email; a user who changed it since gets ErrNotFound.
+func (s *UserStore) VerifyEmail(ctx context.Context, id int, email string) (model.User, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	if err := ctxErr("VerifyEmail", ctx); err != nil {
+		return model.User{}, err // Give up if the caller has already gone away
+	}
+
+	user, ok := s.users[id]
+	if !ok || !strings.EqualFold(user.Email, email) {
+		return model.User{}, notFound("VerifyEmail", id) // Return an error if the user or email is gone
+	}
+	user.EmailVerified = true
+	user.Version++ // The flag is part of the user's representation
+	s.users[id] = user
 	return user, nil
 }
 
+// GetUserByEmail retrieves a user by their email, ignoring case.
+func (s *UserStore) GetUserByEmail(ctx context.Context, email string) (model.User, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	if err := ctxErr("GetUserByEmail", ctx); err != nil {
+		return model.User{}, err // Give up if the caller has already gone away
+	}
+
+	id, ok := s.byEmail[strings.ToLower(email)]
+	if !ok {
+		return model.User{}, &Error{Op: "GetUserByEmail", Kind: ErrNotFound} // Return an error if no user has the email
+	}
+	return s.users[id], nil
+}
+
+// SetPassword replaces the password hash of a user. The version is not
+// bumped, since the hash is not part of the user's representation.
+func (s *UserStore) SetPassword(ctx context.Context, id int, hash string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	if err := ctxErr("SetPassword", ctx); err != nil {
+		return err // Give up if the caller has already gone away
+	}
+
+	user, ok := s.users[id]
+	if !ok {
+		return notFound("SetPassword", id) // Return an error if the user doesn't exist
+	}
+	user.PasswordHash = hash
+	s.users[id] = user
+	return nil
+}
+
 // DeleteUser removes a user from the store by their ID.
-func (s *UserStore) DeleteUser(id int) error {
+func (s *UserStore) DeleteUser(ctx context.Context, id int, ifVersion int) error {
 	s.Lock()
 	defer s.Unlock()
 
-	if _, ok := s.users[id]; !ok {
-		return errors.New("user not found") // Return an error if the user doesn't exist
+	if err := ctxErr("DeleteUser", ctx); err != nil {
+		return err // Give up if the caller has already gone away
+	}
+
+	existing, ok := s.users[id]
+	if !ok {
+		return notFound("DeleteUser", id) // Return an error if the user doesn't exist
+	}
+	if ifVersion != 0 && existing.Version != ifVersion {
+		return versionMismatch("DeleteUser", id, existing.Version) // Someone else wrote first
+	}
+	delete(s.users, id)                                // Remove the user from the map
+	delete(s.byEmail, strings.ToLower(existing.Email)) // Free the email for reuse
+	return nil
+}
+
+// ctxErr returns a store error if the context has been cancelled or its deadline has passed.
+func ctxErr(op string, ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return &Error{Op: op, Err: err}
 	}
-	delete(s.users, id) // Remove the user from the map
 	return nil
 }
@@ -0,0 +1,193 @@
+package test
+
+import (
+	"Curd/auth"
+	"Curd/authz"
+	"Curd/config"
+	"Curd/handler"
+	"Curd/model"
+	"Curd/router"
+	"Curd/store"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// setupAPIKeyServer returns a router accepting admin bearer tokens and API keys from the store.
+func setupAPIKeyServer(t *testing.T, keys store.APIKeyStoreInterface) http.Handler {
+	policy, err := authz.NewPolicy(config.Default().Authz.Roles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return router.NewRouter(&handler.UserHandler{Store: NewMockUserStore(), Policy: policy},
+		router.WithAPIKeys(&handler.APIKeyHandler{Store: keys, Policy: policy}),
+		router.WithAuthenticator("Bearer", newTestVerifier(t, nil)),
+		router.WithAuthenticator("ApiKey", &auth.APIKeyAuthenticator{Store: keys}),
+	)
+}
+
+// TestAPIKeys_Lifecycle tests creating, using, rotating and revoking an API key.
+func TestAPIKeys_Lifecycle(t *testing.T) {
+	server := setupAPIKeyServer(t, store.NewAPIKeyStore())
+	admin := "Bearer " + signToken(t, jwt.SigningMethodHS256, testSecret, "", nil)
+	do := func(method, path, authorization, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", authorization)
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+		return w
+	}
+
+	// Create a key that may only list users; its secret is shown once.
+	w := do(http.MethodPost, "/admin/api-keys", admin, `{"name": "nightly-export", "scopes": ["users:list"]}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201 Created, got %d: %s", w.Code, w.Body)
+	}
+	var created struct {
+		ID     int    `json:"id"`
+		Key    string `json:"key"`
+		Prefix string `json:"prefix"`
+		Hash   string `json:"hash"`
+	}
+	json.NewDecoder(w.Body).Decode(&created)
+	if !strings.HasPrefix(created.Key, created.Prefix+"_") || created.Hash != "" || w.Header().Get("Cache-Control") != "no-store" {
+		t.Fatalf("expected the key once, without its hash, got %+v", created)
+	}
+
+	// The listing never shows secrets.
+	w = do(http.MethodGet, "/admin/api-keys", admin, "")
+	if w.Code != http.StatusOK || strings.Contains(w.Body.String(), created.Key) || !strings.Contains(w.Body.String(), created.Prefix) {
+		t.Errorf("expected the key listed by prefix only, got %d: %s", w.Code, w.Body)
+	}
+
+	// The key grants its scopes and nothing else.
+	if w := do(http.MethodGet, "/users", "ApiKey "+created.Key, ""); w.Code != http.StatusOK {
+		t.Errorf("expected the key to list users, got %d", w.Code)
+	}
+	if w := do(http.MethodPost, "/users", "ApiKey "+created.Key, `{"name": "Eve", "email": "eve@example.com"}`); w.Code != http.StatusForbidden {
+		t.Errorf("expected the key not to create users, got %d", w.Code)
+	}
+	if w := do(http.MethodGet, "/admin/api-keys", "ApiKey "+created.Key, ""); w.Code != http.StatusForbidden {
+		t.Errorf("expected the key not to manage keys, got %d", w.Code)
+	}
+
+	// Rotating replaces the secret at once.
+	w = do(http.MethodPost, "/admin/api-keys/1/rotate", admin, "")
+	var rotated struct {
+		Key string `json:"key"`
+	}
+	json.NewDecoder(w.Body).Decode(&rotated)
+	if w.Code != http.StatusOK || rotated.Key == "" || rotated.Key == created.Key {
+		t.Fatalf("expected a new secret, got %d: %+v", w.Code, rotated)
+	}
+	if w := do(http.MethodGet, "/users", "ApiKey "+created.Key, ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected the old secret to be rejected, got %d", w.Code)
+	}
+	if w := do(http.MethodGet, "/users", "ApiKey "+rotated.Key, ""); w.Code != http.StatusOK {
+		t.Errorf("expected the new secret to work, got %d", w.Code)
+	}
+
+	// Revoking disables the key for good.
+	if w := do(http.MethodDelete, "/admin/api-keys/1", admin, ""); w.Code != http.StatusNoContent {
+		t.Fatalf("expected 204 No Content, got %d", w.Code)
+	}
+	if w := do(http.MethodGet, "/users", "ApiKey "+rotated.Key, ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected the revoked key to be rejected, got %d", w.Code)
+	}
+	if w := do(http.MethodPost, "/admin/api-keys/1/rotate", admin, ""); w.Code != http.StatusConflict {
+		t.Errorf("expected a revoked key not to be rotated, got %d", w.Code)
+	}
+	if w := do(http.MethodDelete, "/admin/api-keys/9", admin, ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown key, got %d", w.Code)
+	}
+
+	// Scopes must be permissions and expiry must be in the future.
+	w = do(http.MethodPost, "/admin/api-keys", admin, `{"name": "bad", "scopes": ["everything"], "expires_at": "2001-01-01T00:00:00Z"}`)
+	var problem handler.Problem
+	json.NewDecoder(w.Body).Decode(&problem)
+	if w.Code != http.StatusUnprocessableEntity || len(problem.Errors) != 2 {
+		t.Errorf("expected 422 with two invalid fields, got %d: %+v", w.Code, problem)
+	}
+}
+
+// TestAPIKeys_Expired tests that an expired key is rejected with a fresh challenge.
+func TestAPIKeys_Expired(t *testing.T) {
+	keys := store.NewAPIKeyStore()
+	secret, prefix, hash := auth.NewAPIKey()
+	expired := time.Now().Add(-time.Minute)
+	if _, err := keys.CreateAPIKey(context.Background(), model.APIKey{Name: "old", Prefix: prefix, Hash: hash, Scopes: []string{"*"}, ExpiresAt: &expired}); err != nil {
+		t.Fatal(err)
+	}
+	server := setupAPIKeyServer(t, keys)
+
+	req := httptest.NewRequest(http.MethodGet, "/users", nil)
+	req.Header.Set("Authorization", "ApiKey "+secret)
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+
+	var problem handler.Problem
+	json.NewDecoder(w.Body).Decode(&problem)
+	if w.Code != http.StatusUnauthorized || !strings.Contains(problem.Detail, "expired") {
+		t.Errorf("expected 401 for an expired key, got %d: %+v", w.Code, problem)
+	}
+	if !strings.Contains(strings.Join(w.Header().Values("WWW-Authenticate"), "\n"), `ApiKey realm="curd", error="invalid_token"`) {
+		t.Errorf("expected the ApiKey challenge to flag the key, got %v", w.Header().Values("WWW-Authenticate"))
+	}
+}
+
+// TestAPIKeys_ScopeEscalation tests that callers may only grant keys the
+// scopes they hold themselves, when creating and when rotating them.
+func TestAPIKeys_ScopeEscalation(t *testing.T) {
+	server := setupAPIKeyServer(t, store.NewAPIKeyStore())
+	admin := "Bearer " + signToken(t, jwt.SigningMethodHS256, testSecret, "", nil)
+	do := func(method, path, authorization, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", authorization)
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+		return w
+	}
+	create := func(authorization, scopes string) *httptest.ResponseRecorder {
+		return do(http.MethodPost, "/admin/api-keys", authorization, `{"name": "k", "scopes": `+scopes+`}`)
+	}
+
+	// A provisioning key may manage keys and list users, but nothing else.
+	w := create(admin, `["api-keys:create", "api-keys:rotate", "users:list"]`)
+	var limited struct {
+		Key string `json:"key"`
+	}
+	json.NewDecoder(w.Body).Decode(&limited)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201 Created, got %d: %s", w.Code, w.Body)
+	}
+	provisioner := "ApiKey " + limited.Key
+
+	for _, scopes := range []string{`["*"]`, `["users:*"]`, `["users:list", "users:delete"]`} {
+		if w := create(provisioner, scopes); w.Code != http.StatusForbidden {
+			t.Errorf("%s: expected 403 Forbidden, got %d: %s", scopes, w.Code, w.Body)
+		}
+	}
+	if w := create(provisioner, `["users:list"]`); w.Code != http.StatusCreated {
+		t.Errorf("expected a scope the caller holds to be granted, got %d: %s", w.Code, w.Body)
+	}
+
+	// Rotating hands out a new secret for the key's scopes, so the same rule applies.
+	w = create(admin, `["*"]`)
+	var powerful struct {
+		ID int `json:"id"`
+	}
+	json.NewDecoder(w.Body).Decode(&powerful)
+	if w := do(http.MethodPost, "/admin/api-keys/"+strconv.Itoa(powerful.ID)+"/rotate", provisioner, ""); w.Code != http.StatusForbidden {
+		t.Errorf("expected 403 rotating a key broader than the caller, got %d: %s", w.Code, w.Body)
+	}
+	if w := do(http.MethodPost, "/admin/api-keys/"+strconv.Itoa(powerful.ID)+"/rotate", admin, ""); w.Code != http.StatusOK {
+		t.Errorf("expected an admin to rotate it, got %d: %s", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,181 @@
+package test
+
+import (
+	"Curd/auth"
+	"Curd/handler"
+	"Curd/router"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// testSecret signs the HS256 tokens used in tests.
+var testSecret = []byte("0123456789abcdef0123456789abcdef")
+
+// signToken signs a token for the test issuer and audience. Claims override
+// the defaults: subject "7", role "admin" and expiry in an hour.
+func signToken(t *testing.T, method jwt.SigningMethod, key any, kid string, claims jwt.MapClaims) string {
+	t.Helper()
+	c := jwt.MapClaims{
+		"iss":   "https://issuer.test/",
+		"aud":   "curd",
+		"sub":   "7",
+		"roles": []string{"admin"},
+		"exp":   time.Now().Add(time.Hour).Unix(),
+	}
+	for k, v := range claims {
+		c[k] = v
+	}
+	token := jwt.NewWithClaims(method, c)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+// newTestVerifier returns a verifier for the test issuer, audience and secret.
+func newTestVerifier(t *testing.T, keys *auth.KeySet) *auth.JWTVerifier {
+	t.Helper()
+	v, err := auth.NewJWTVerifier(auth.JWTConfig{Issuer: "https://issuer.test/", Audience: "curd", HMACSecret: testSecret, Keys: keys})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+// TestAuth_BearerToken tests that the API requires a valid bearer token while the probes stay public.
+func TestAuth_BearerToken(t *testing.T) {
+	server := router.NewRouter(&handler.UserHandler{Store: NewMockUserStore()},
+		router.WithAuthenticator("Bearer", newTestVerifier(t, nil)))
+
+	tests := []struct {
+		name   string
+		path   string
+		header string
+		want   int
+	}{
+		{"valid token", "/users", "Bearer " + signToken(t, jwt.SigningMethodHS256, testSecret, "", nil), http.StatusOK},
+		{"lowercase scheme", "/users", "bearer " + signToken(t, jwt.SigningMethodHS256, testSecret, "", nil), http.StatusOK},
+		{"no header", "/users", "", http.StatusUnauthorized},
+		{"other scheme", "/users", "Basic YWxpY2U6c2VjcmV0", http.StatusUnauthorized},
+		{"garbage", "/users", "Bearer not-a-jwt", http.StatusUnauthorized},
+		{"wrong secret", "/users", "Bearer " + signToken(t, jwt.SigningMethodHS256, []byte("another-secret-of-at-least-32-bytes"), "", nil), http.StatusUnauthorized},
+		{"wrong issuer", "/users", "Bearer " + signToken(t, jwt.SigningMethodHS256, testSecret, "", jwt.MapClaims{"iss": "https://evil.test/"}), http.StatusUnauthorized},
+		{"wrong audience", "/users", "Bearer " + signToken(t, jwt.SigningMethodHS256, testSecret, "", jwt.MapClaims{"aud": "billing"}), http.StatusUnauthorized},
+		{"expired", "/users", "Bearer " + signToken(t, jwt.SigningMethodHS256, testSecret, "", jwt.MapClaims{"exp": time.Now().Add(-time.Hour).Unix()}), http.StatusUnauthorized},
+		{"no expiry", "/users", "Bearer " + signToken(t, jwt.SigningMethodHS256, testSecret, "", jwt.MapClaims{"exp": nil}), http.StatusUnauthorized},
+		{"alg none", "/users", "Bearer " + signToken(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, "", nil), http.StatusUnauthorized},
+		{"unmatched route", "/nowhere", "", http.StatusUnauthorized},
+		{"liveness probe", "/healthz", "", http.StatusOK},
+		{"readiness probe", "/readyz", "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			w := httptest.NewRecorder()
+			server.ServeHTTP(w, req)
+
+			if w.Code != tt.want {
+				t.Fatalf("expected %d, got %d: %s", tt.want, w.Code, w.Body)
+			}
+			if w.Code == http.StatusUnauthorized {
+				if !strings.HasPrefix(w.Header().Get("WWW-Authenticate"), "Bearer ") {
+					t.Errorf("expected a Bearer challenge, got %q", w.Header().Get("WWW-Authenticate"))
+				}
+				var problem handler.Problem
+				json.NewDecoder(w.Body).Decode(&problem)
+				if problem.Type != "/problems/unauthenticated" {
+					t.Errorf("expected an unauthenticated problem, got %+v", problem)
+				}
+			}
+		})
+	}
+}
+
+// TestAuth_Principal tests that the claims of the token reach handlers as the principal.
+func TestAuth_Principal(t *testing.T) {
+	var got *auth.Principal
+	server := handler.Authenticate(map[string]auth.Authenticator{"Bearer": newTestVerifier(t, nil)}, nil)(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got = auth.PrincipalFromContext(r.Context())
+		}))
+
+	req := httptest.NewRequest(http.MethodGet, "/users", nil)
+	req.Header.Set("Authorization", "Bearer "+signToken(t, jwt.SigningMethodHS256, testSecret, "", jwt.MapClaims{"sub": "42", "roles": []string{"reader"}, "scope": "users:read users:list"}))
+	server.ServeHTTP(httptest.NewRecorder(), req)
+
+	if got == nil {
+		t.Fatal("expected a principal in the request context")
+	}
+	if got.Subject != "42" || !got.HasRole("reader") || got.HasRole("admin") || len(got.Scopes) != 2 || got.Method != "jwt" {
+		t.Errorf("unexpected principal %+v", got)
+	}
+}
+
+// TestAuth_JWKS tests that RS256 and ES256 tokens are verified against the key with their key ID.
+func TestAuth_JWKS(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	b64 := func(i *big.Int, size int) string {
+		return base64.RawURLEncoding.EncodeToString(i.FillBytes(make([]byte, size)))
+	}
+	jwks := fmt.Sprintf(`{"keys": [
+		{"kty": "RSA", "kid": "rsa-1", "use": "sig", "n": %q, "e": "AQAB"},
+		{"kty": "EC", "kid": "ec-1", "crv": "P-256", "x": %q, "y": %q},
+		{"kty": "oct", "kid": "enc-1", "use": "enc", "k": "c2VjcmV0"}
+	]}`, b64(rsaKey.N, 256), b64(ecKey.X, 32), b64(ecKey.Y, 32))
+	keys, err := auth.ParseJWKS([]byte(jwks))
+	if err != nil {
+		t.Fatalf("failed to parse JWKS: %v", err)
+	}
+	verifier := newTestVerifier(t, keys)
+
+	tests := []struct {
+		name  string
+		token string
+		ok    bool
+	}{
+		{"RS256", signToken(t, jwt.SigningMethodRS256, rsaKey, "rsa-1", nil), true},
+		{"ES256", signToken(t, jwt.SigningMethodES256, ecKey, "ec-1", nil), true},
+		{"unknown kid", signToken(t, jwt.SigningMethodES256, ecKey, "ec-2", nil), false},
+		{"wrong key", signToken(t, jwt.SigningMethodES256, otherKey, "ec-1", nil), false},
+		{"algorithm of another key", signToken(t, jwt.SigningMethodES256, ecKey, "rsa-1", nil), false},
+		{"HMAC with a public key", signToken(t, jwt.SigningMethodHS256, []byte(jwks), "rsa-1", nil), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := verifier.Authenticate(context.Background(), tt.token)
+			if (err == nil) != tt.ok {
+				t.Errorf("expected ok=%v, got %v", tt.ok, err)
+			}
+		})
+	}
+}
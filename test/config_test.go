@@ -0,0 +1,111 @@
+package test
+
+import (
+	"Curd/config"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// envMap returns a getenv function backed by a map.
+func envMap(env map[string]string) func(string) string {
+	return func(key string) string { return env[key] }
+}
+
+// writeConfigFile writes a configuration file into a temporary directory.
+func writeConfigFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// TestLoad_Precedence tests that flags override the environment, which overrides the file, which overrides defaults.
+func TestLoad_Precedence(t *testing.T) {
+	path := writeConfigFile(t, "curd.yaml", `
+http:
+  addr: ":9000"
+  request_timeout: 3s
+store:
+  backend: postgres
+  dsn: "host=file"
+`)
+	cfg, rest, err := config.Load(
+		[]string{"-config", path, "-http.addr", ":7000", "migrate", "up"},
+		envMap(map[string]string{"CURD_HTTP_ADDR": ":8000", "CURD_STORE_DSN": "host=env"}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.HTTP.Addr != ":7000" || cfg.Store.DSN != "host=env" || cfg.HTTP.RequestTimeout != 3*time.Second || cfg.Notifiers.FCM.Topic != "user-updates" {
+		t.Errorf("unexpected configuration:\n%s", cfg)
+	}
+	if strings.Join(rest, " ") != "migrate up" {
+		t.Errorf("expected the subcommand to be left over, got %v", rest)
+	}
+}
+
+// TestLoad_TOML tests that TOML files are read like YAML files.
+func TestLoad_TOML(t *testing.T) {
+	path := writeConfigFile(t, "curd.toml", `
+[notifiers.email]
+enabled = true
+sendgrid_api_key = "SG.secret"
+`)
+	cfg, _, err := config.Load(nil, envMap(map[string]string{"CURD_CONFIG": path, "CURD_STORE_DSN": "host=env"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.Notifiers.Email.Enabled || cfg.Notifiers.Email.SendGridAPIKey != "SG.secret" {
+		t.Errorf("expected email settings from the file, got %+v", cfg.Notifiers.Email)
+	}
+}
+
+// TestDefault_Postgres tests that the service stores users in PostgreSQL
+// unless told otherwise, and so needs a DSN to start.
+func TestDefault_Postgres(t *testing.T) {
+	if backend := config.Default().Store.Backend; backend != config.BackendPostgres {
+		t.Errorf("expected the postgres backend by default, got %q", backend)
+	}
+	if _, _, err := config.Load(nil, envMap(nil)); err == nil || !strings.Contains(err.Error(), "store.dsn") {
+		t.Errorf("expected the missing DSN to be reported, got %v", err)
+	}
+}
+
+// TestLoad_Invalid tests that unknown settings and inconsistent values are rejected.
+func TestLoad_Invalid(t *testing.T) {
+	for name, args := range map[string][]string{
+		"unknown backend":  {"-store.backend", "mongo"},
+		"missing dsn":      {"-store.backend", "postgres"},
+		"missing key":      {"-notifiers.email.enabled"},
+		"bad duration":     {"-http.request_timeout", "soon"},
+		"unknown file key": {"-config", writeConfigFile(t, "bad.yaml", "store:\n  dns: x\n")},
+	} {
+		if _, _, err := config.Load(args, envMap(nil)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+// TestConfig_Redacted tests that dumping the configuration hides secrets.
+func TestConfig_Redacted(t *testing.T) {
+	cfg, _, err := config.Load([]string{
+		"-store.backend", "postgres",
+		"-store.dsn", "postgres://app:hunter2@db:5432/users",
+		"-notifiers.email.enabled",
+		"-notifiers.email.sendgrid_api_key", "SG.secret",
+	}, envMap(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dump := cfg.String()
+	if strings.Contains(dump, "hunter2") || strings.Contains(dump, "SG.secret") {
+		t.Errorf("expected secrets to be redacted:\n%s", dump)
+	}
+	if !strings.Contains(dump, "db:5432") {
+		t.Errorf("expected the database host to stay visible:\n%s", dump)
+	}
+}
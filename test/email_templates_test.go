@@ -0,0 +1,100 @@
+package test
+
+import (
+	"Curd/model"
+	"Curd/notification"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// TestDefaultTemplates_Render tests that every event template renders a subject and both bodies.
+func TestDefaultTemplates_Render(t *testing.T) {
+	user := model.User{ID: 1, Name: "<Alice>", Email: "alice@example.com"}
+
+	for _, name := range []string{notification.TemplateWelcome, notification.TemplateProfileUpdated, notification.TemplateAccountDeleted} {
+		email, err := notification.DefaultTemplates().Render(name, user)
+		if err != nil {
+			t.Fatalf("failed to render %s: %v", name, err)
+		}
+		if email.Subject == "" || strings.Contains(email.Subject, "\n") {
+			t.Errorf("%s: expected a single-line subject, got %q", name, email.Subject)
+		}
+
+		// The plaintext body keeps the name as-is; the HTML body escapes it.
+		if !strings.Contains(email.Text, "<Alice>") {
+			t.Errorf("%s: expected plaintext body to contain the name, got %q", name, email.Text)
+		}
+		if !strings.Contains(email.HTML, "&lt;Alice&gt;") {
+			t.Errorf("%s: expected HTML body to escape the name, got %q", name, email.HTML)
+		}
+	}
+}
+
+// TestLoadTemplates_RequiresSubject tests that a template without a subject is rejected.
+func TestLoadTemplates_RequiresSubject(t *testing.T) {
+	fsys := fstest.MapFS{"welcome.txt.tmpl": {Data: []byte("Hello {{.Name}}")}}
+
+	if _, err := notification.LoadTemplates(fsys, notification.DefaultCatalog()); err == nil {
+		t.Error("expected an error for a template without a subject")
+	}
+}
+
+// TestLoadTemplates_NilCatalog tests that templates loaded without a catalog use the default copy.
+func TestLoadTemplates_NilCatalog(t *testing.T) {
+	fsys := fstest.MapFS{"hello.txt.tmpl": {Data: []byte(`{{define "subject"}}{{.T "email.welcome.subject"}}{{end}}{{.T "email.greeting"}}`)}}
+	templates, err := notification.LoadTemplates(fsys, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	email, err := templates.RenderData("hello", notification.TemplateData{User: model.User{Name: "Alice", Locale: "ja"}})
+	if err != nil || email.Subject != "Welcome to Our Service" || email.Text != "Hello Alice," {
+		t.Errorf("expected the English copy, got %+v, %v", email, err)
+	}
+}
+
+// TestRender_TextOnly tests that a template without an HTML variant never
+// sends its unescaped plaintext as an HTML body.
+func TestRender_TextOnly(t *testing.T) {
+	fsys := fstest.MapFS{"hello.txt.tmpl": {Data: []byte(`{{define "subject"}}Hi{{end}}Hello {{.Name}}`)}}
+	templates, err := notification.LoadTemplates(fsys, notification.DefaultCatalog())
+	if err != nil {
+		t.Fatal(err)
+	}
+	email, err := templates.Render("hello", model.User{Name: "<script>alert(1)</script>"})
+	if err != nil || email.Text != "Hello <script>alert(1)</script>" || email.HTML != "" {
+		t.Errorf("expected a text-only email, got %+v, %v", email, err)
+	}
+}
+
+// TestDefaultTemplates_Localized tests that emails are rendered in the user's locale with fallbacks.
+func TestDefaultTemplates_Localized(t *testing.T) {
+	tests := []struct {
+		locale  string
+		subject string
+	}{
+		{"pt-BR", "Seu perfil foi atualizado"},   // Defined in pt-BR.
+		{"pt-PT", "O seu perfil foi atualizado"}, // Falls back to pt.
+		{"es_MX", "Tu perfil se ha actualizado"}, // Normalized, then falls back to es.
+		{"ja", "Your profile has been updated"},  // Falls back to en.
+		{"", "Your profile has been updated"},    // No locale uses en.
+	}
+	for _, tt := range tests {
+		user := model.User{Name: "Alice", Email: "alice@example.com", Locale: tt.locale}
+		email, err := notification.DefaultTemplates().Render(notification.TemplateProfileUpdated, user)
+		if err != nil {
+			t.Fatalf("%q: failed to render: %v", tt.locale, err)
+		}
+		if email.Subject != tt.subject {
+			t.Errorf("%q: expected subject %q, got %q", tt.locale, tt.subject, email.Subject)
+		}
+	}
+}
+
+// TestCatalog_Chain tests the locale fallback chain.
+func TestCatalog_Chain(t *testing.T) {
+	got := strings.Join(notification.DefaultCatalog().Chain("zh_hant_tw"), ",")
+	if want := "zh-Hant-TW,zh-Hant,zh,en"; got != want {
+		t.Errorf("expected chain %s, got %s", want, got)
+	}
+}
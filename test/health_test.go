@@ -0,0 +1,70 @@
+package test
+
+import (
+	"Curd/handler"
+	"Curd/health"
+	"Curd/router"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestReadiness tests that /readyz reports each component and fails while any is down.
+func TestReadiness(t *testing.T) {
+	dbErr := errors.New("connection refused")
+	failing := true
+	registry := health.NewRegistry(time.Second)
+	registry.Register("store", health.CheckerFunc(func(ctx context.Context) error {
+		if failing {
+			return dbErr
+		}
+		return nil
+	}))
+	registry.Register("queue", health.CheckerFunc(func(ctx context.Context) error { return nil }))
+	server := router.NewRouter(&handler.UserHandler{Store: NewMockUserStore()}, router.WithHealth(registry))
+
+	probe := func() (int, health.Report) {
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		var report health.Report
+		if err := json.NewDecoder(w.Body).Decode(&report); err != nil {
+			t.Fatalf("failed to decode report: %v", err)
+		}
+		return w.Code, report
+	}
+
+	code, report := probe()
+	if code != http.StatusServiceUnavailable || report.Status != health.StatusDown || len(report.Components) != 2 {
+		t.Fatalf("expected 503 with two components, got %d %+v", code, report)
+	}
+	if c := report.Components[1]; c.Name != "store" || c.Status != health.StatusDown || c.LastError != dbErr.Error() {
+		t.Errorf("expected the store to be down with its error, got %+v", c)
+	}
+
+	// Once the store recovers the service is ready, and the last error is still reported.
+	failing = false
+	code, report = probe()
+	if code != http.StatusOK || report.Status != health.StatusUp {
+		t.Fatalf("expected 200 once recovered, got %d %+v", code, report)
+	}
+	if c := report.Components[1]; c.Status != health.StatusUp || c.LastError != dbErr.Error() || c.LastErrorAt == nil {
+		t.Errorf("expected the store to be up and remember its last error, got %+v", c)
+	}
+}
+
+// TestLiveness tests that /healthz succeeds without probing dependencies.
+func TestLiveness(t *testing.T) {
+	registry := health.NewRegistry(time.Second)
+	registry.Register("store", health.CheckerFunc(func(ctx context.Context) error { return errors.New("down") }))
+	server := router.NewRouter(&handler.UserHandler{Store: NewMockUserStore()}, router.WithHealth(registry))
+
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("expected 200 OK, got %d", w.Code)
+	}
+}
@@ -0,0 +1,61 @@
+package test
+
+import (
+	"Curd/lifecycle"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestManager_GracefulShutdown tests that in-flight requests finish before the hooks run, newest hook first.
+func TestManager_GracefulShutdown(t *testing.T) {
+	started := make(chan struct{})
+	manager := &lifecycle.Manager{
+		Server: &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			time.Sleep(50 * time.Millisecond) // Still running when shutdown begins.
+			io.WriteString(w, "done")
+		})},
+		ShutdownTimeout: time.Second,
+	}
+	var order []string
+	manager.OnShutdown("database", func(context.Context) error { order = append(order, "database"); return nil })
+	manager.OnShutdown("queue", func(context.Context) error { order = append(order, "queue"); return nil })
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- manager.Serve(ctx, ln) }()
+
+	// Start a slow request, then ask the manager to stop while it is in flight.
+	resp := make(chan string, 1)
+	go func() {
+		r, err := http.Get("http://" + ln.Addr().String())
+		if err != nil {
+			resp <- err.Error()
+			return
+		}
+		defer r.Body.Close()
+		body, _ := io.ReadAll(r.Body)
+		resp <- string(body)
+	}()
+	<-started
+	cancel()
+
+	if err := <-served; err != nil {
+		t.Fatalf("expected a clean shutdown, got %v", err)
+	}
+	if body := <-resp; body != "done" {
+		t.Errorf("expected the in-flight request to complete, got %q", body)
+	}
+	if want := []string{"queue", "database"}; !reflect.DeepEqual(order, want) {
+		t.Errorf("expected hooks to run as %v, got %v", want, order)
+	}
+}
@@ -0,0 +1,70 @@
+package test
+
+import (
+	"Curd/handler"
+	"Curd/logging"
+	"Curd/model"
+	"Curd/router"
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestLogging_RedactsPII tests that names and emails are masked, including inside a logged user.
+func TestLogging_RedactsPII(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := logging.New(&buf, logging.Options{Level: "info", RedactPII: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Info("created", "user", model.User{ID: 1, Name: "Alice", Email: "alice@example.com"}, "password", "hunter2")
+
+	line := buf.String()
+	for _, secret := range []string{"Alice", "alice@", "hunter2"} {
+		if strings.Contains(line, secret) {
+			t.Errorf("expected %q to be redacted: %s", secret, line)
+		}
+	}
+	if !strings.Contains(line, `"email":"***@example.com"`) || !strings.Contains(line, `"id":1`) {
+		t.Errorf("expected the email domain and ID to stay visible: %s", line)
+	}
+}
+
+// TestLogging_RequestID tests that the request ID is echoed, used in problems and attached to every log line.
+func TestLogging_RequestID(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	server := router.NewRouter(&handler.UserHandler{Store: NewMockUserStore()}, router.WithLogger(logger))
+
+	req := httptest.NewRequest(http.MethodGet, "/users/5", nil)
+	req.Header.Set("X-Request-ID", "req-42")
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+
+	if got := w.Header().Get("X-Request-ID"); got != "req-42" {
+		t.Errorf("expected the request ID to be echoed, got %q", got)
+	}
+	var problem handler.Problem
+	json.NewDecoder(w.Body).Decode(&problem)
+	if problem.RequestID != "req-42" {
+		t.Errorf("expected the problem to carry the request ID, got %q", problem.RequestID)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) == 0 || lines[0] == "" {
+		t.Fatal("expected the request to be logged")
+	}
+	for _, line := range lines {
+		var entry map[string]any
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("expected JSON log lines, got %s", line)
+		}
+		if entry["request_id"] != "req-42" {
+			t.Errorf("expected request_id on every line, got %s", line)
+		}
+	}
+}
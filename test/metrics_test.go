@@ -0,0 +1,100 @@
+package test
+
+import (
+	"Curd/handler"
+	"Curd/metrics"
+	"Curd/model"
+	"Curd/notification"
+	"Curd/router"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// failingNotifier is a notifier whose every send fails.
+type failingNotifier struct{}
+
+// Notify always fails.
+func (failingNotifier) Notify(ctx context.Context, event notification.Event) error {
+	return errors.New("provider down")
+}
+
+// TestMetrics tests that routes, store calls and notification sends show up at /metrics.
+func TestMetrics(t *testing.T) {
+	m := metrics.New()
+	server := router.NewRouter(&handler.UserHandler{Store: m.InstrumentStore(NewMockUserStore())}, router.WithMetrics(m))
+
+	server.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/42", nil))
+	m.InstrumentNotifier("email", failingNotifier{}).Notify(context.Background(), notification.Event{Type: notification.EventUserCreated, User: model.User{}})
+
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body, _ := io.ReadAll(w.Body)
+	for _, want := range []string{
+		`curd_http_requests_total{method="GET",route="/users/",status="404"} 1`,
+		`curd_store_errors_total{kind="not_found",method="GetUser"} 1`,
+		`curd_store_operation_duration_seconds_count{method="GetUser"} 1`,
+		`curd_notification_sends_total{channel="email",outcome="failure"} 1`,
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("expected %s in the metrics", want)
+		}
+	}
+}
+
+// TestMetrics_RouteTemplate tests that IDs in paths are collapsed so each route is one series.
+func TestMetrics_RouteTemplate(t *testing.T) {
+	m := metrics.New()
+	mockStore := NewMockUserStore()
+	mockStore.CreateUser(context.Background(), model.User{Name: "Alice", Email: "alice@example.com"})
+	server := router.NewRouter(&handler.UserHandler{Store: mockStore}, router.WithMetrics(m))
+
+	server.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/1", nil))
+
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if want := `curd_http_requests_total{method="GET",route="/users/{id}",status="200"} 1`; !strings.Contains(w.Body.String(), want) {
+		t.Errorf("expected %s in the metrics", want)
+	}
+}
+
+// TestMetrics_RouteCardinality tests that requests rejected before reaching a
+// handler, on arbitrary paths, still share the series of their route.
+func TestMetrics_RouteCardinality(t *testing.T) {
+	m := metrics.New()
+	server := router.NewRouter(&handler.UserHandler{Store: NewMockUserStore()}, router.WithMetrics(m),
+		router.WithAuthenticator("Bearer", newTestVerifier(t, nil)),
+	)
+
+	for i := 0; i < 20; i++ {
+		for _, path := range []string{"/users/" + strconv.Itoa(i) + "x-junk", "/users/junk-" + strconv.Itoa(i) + "/password", "/users/a/b/c" + strconv.Itoa(i)} {
+			w := httptest.NewRecorder()
+			server.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+			if w.Code != http.StatusUnauthorized {
+				t.Fatalf("%s: expected 401 Unauthorized, got %d", path, w.Code)
+			}
+		}
+	}
+
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	var series []string
+	for _, line := range strings.Split(w.Body.String(), "\n") {
+		if strings.HasPrefix(line, "curd_http_requests_total{") && strings.Contains(line, `status="401"`) {
+			series = append(series, line)
+		}
+	}
+	want := []string{
+		`curd_http_requests_total{method="GET",route="/users/",status="401"} 20`,
+		`curd_http_requests_total{method="GET",route="/users/{id}",status="401"} 20`,
+		`curd_http_requests_total{method="GET",route="/users/{id}/password",status="401"} 20`,
+	}
+	if strings.Join(series, "\n") != strings.Join(want, "\n") {
+		t.Errorf("expected one series per route, got:\n%s", strings.Join(series, "\n"))
+	}
+}
@@ -0,0 +1,106 @@
+package test
+
+import (
+	"Curd/model"
+	"Curd/store"
+	"context"
+	"database/sql"
+	"os"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	_ "github.com/jackc/pgx/v5/stdlib" // Registers the "pgx" database/sql driver.
+)
+
+// TestDefaultMigrations tests that the embedded migrations load in order with both directions.
+func TestDefaultMigrations(t *testing.T) {
+	migrations := store.DefaultMigrations()
+	if len(migrations) == 0 {
+		t.Fatal("expected embedded migrations")
+	}
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			t.Errorf("expected version %d at position %d, got %d", i+1, i, m.Version)
+		}
+		if strings.TrimSpace(m.Up) == "" || strings.TrimSpace(m.Down) == "" {
+			t.Errorf("migration %d_%s is missing a script", m.Version, m.Name)
+		}
+	}
+}
+
+// TestLoadMigrations_Invalid tests that malformed migration sets are rejected.
+func TestLoadMigrations_Invalid(t *testing.T) {
+	for name, fsys := range map[string]fstest.MapFS{
+		"missing down": {"0001_init.up.sql": {Data: []byte("SELECT 1")}},
+		"bad name":     {"init.up.sql": {Data: []byte("SELECT 1")}, "init.down.sql": {Data: []byte("SELECT 1")}},
+		"duplicate version": {
+			"0001_a.up.sql": {Data: []byte("SELECT 1")}, "0001_a.down.sql": {Data: []byte("SELECT 1")},
+			"0001_b.up.sql": {Data: []byte("SELECT 1")}, "0001_b.down.sql": {Data: []byte("SELECT 1")},
+		},
+	} {
+		if _, err := store.LoadMigrations(fsys); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+// TestMigrations_AutoMigratedBaseline tests that the first migration brings a
+// users table created by GORM's AutoMigrate, which only has id, name and email,
+// up to the columns the store needs.
+func TestMigrations_AutoMigratedBaseline(t *testing.T) {
+	up := store.DefaultMigrations()[0].Up
+	for _, column := range []string{"locale text", "version bigint NOT NULL DEFAULT 1"} {
+		if !strings.Contains(up, "ALTER TABLE users ADD COLUMN IF NOT EXISTS "+column) {
+			t.Errorf("expected migration 0001 to add %q to existing users tables", column)
+		}
+	}
+
+	// Run against a real database when one is provided. Its tables are dropped,
+	// so point CURD_TEST_POSTGRES_DSN at a scratch database only.
+	dsn := os.Getenv("CURD_TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("CURD_TEST_POSTGRES_DSN not set")
+	}
+	db, err := sql.Open("pgx", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+	for _, stmt := range []string{
+		"DROP TABLE IF EXISTS schema_migrations, outbox_events, api_keys, users",
+		"CREATE TABLE users (id bigserial PRIMARY KEY, name text, email text)", // The AutoMigrate baseline.
+		"INSERT INTO users (name, email) VALUES ('Alice', 'alice@example.com')",
+	} {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			t.Fatalf("failed to prepare the baseline schema: %v", err)
+		}
+	}
+
+	pg, err := store.NewPostgresUserStore(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pg.Close()
+	migrator, err := pg.Migrator()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatalf("failed to migrate the baseline schema: %v", err)
+	}
+
+	// Existing rows get a version, and new users can be written and read back.
+	user, err := pg.GetUser(ctx, 1)
+	if err != nil || user.Version != 1 {
+		t.Errorf("expected the existing user at version 1, got %+v, %v", user, err)
+	}
+	created, err := pg.CreateUser(ctx, model.User{Name: "Bob", Email: "bob@example.com", Locale: "pt-BR"})
+	if err != nil {
+		t.Fatalf("failed to create a user: %v", err)
+	}
+	if got, err := pg.GetUser(ctx, created.ID); err != nil || got.Locale != "pt-BR" || got.Version != 1 {
+		t.Errorf("expected the new user with its locale, got %+v, %v", got, err)
+	}
+}
@@ -0,0 +1,47 @@
+package test
+
+import (
+	"Curd/notification"
+	"context"
+	"testing"
+	"time"
+)
+
+// MockMailer is a notification.Mailer that hands every email it is asked to
+// send to the test, which may wait for them with Next.
+type MockMailer struct {
+	sent chan notification.TemplateData // Emails sent so far and not yet taken.
+}
+
+// NewMockMailer returns a mailer buffering up to 16 emails.
+func NewMockMailer() *MockMailer {
+	return &MockMailer{sent: make(chan notification.TemplateData, 16)}
+}
+
+// Send records the email and never fails.
+func (m *MockMailer) Send(ctx context.Context, name string, data notification.TemplateData) error {
+	m.sent <- data // Hand the email to the test.
+	return nil
+}
+
+// Next waits for the next email, failing the test if none is sent in time.
+func (m *MockMailer) Next(t *testing.T) notification.TemplateData {
+	t.Helper()
+	select {
+	case data := <-m.sent:
+		return data
+	case <-time.After(time.Second):
+		t.Fatal("expected an email to be sent")
+		return notification.TemplateData{}
+	}
+}
+
+// None fails the test if an email is sent shortly.
+func (m *MockMailer) None(t *testing.T) {
+	t.Helper()
+	select {
+	case data := <-m.sent:
+		t.Errorf("expected no email, got one to %s", data.Email)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
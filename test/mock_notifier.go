@@ -0,0 +1,28 @@
+package test
+
+import (
+	"Curd/notification"
+	"context"
+	"sync"
+)
+
+// MockNotifier is a notification.Notifier that records every event it receives.
+type MockNotifier struct {
+	mu     sync.Mutex
+	Events []notification.Event // Events delivered so far, in order.
+}
+
+// Notify records the event and never fails.
+func (m *MockNotifier) Notify(ctx context.Context, event notification.Event) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Events = append(m.Events, event) // Remember the event for assertions.
+	return nil
+}
+
+// Received returns a copy of the events delivered so far.
+func (m *MockNotifier) Received() []notification.Event {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]notification.Event(nil), m.Events...)
+}
@@ -2,7 +2,9 @@ package test
 
 import (
 	"Curd/model"
-	"errors"
+	"Curd/store"
+	"context"
+	"strings"
 )
 
 // MockUserStore is an in-memory mock implementation of a user store.
@@ -21,47 +23,125 @@ func NewMockUserStore() *MockUserStore {
 }
 
 // CreateUser adds a new user to the store and assigns a unique ID to the user.
-func (m *MockUserStore) CreateUser(user model.User) (model.User, error) {
-	user.ID = m.NextID      // Assign the next available ID to the user.
-	m.Users[user.ID] = user // Add the user to the map.
-	m.NextID++              // Increment the next available ID.
-	return user, nil        // Return the created user and no error.
+func (m *MockUserStore) CreateUser(ctx context.Context, user model.User) (model.User, error) {
+	if err := m.emailTaken(0, user.Email); err != nil {
+		return model.User{}, err // Return a conflict if another user has the email.
+	}
+	user.ID = m.NextID         // Assign the next available ID to the user.
+	user.Version = 1           // Start the user's version history.
+	user.EmailVerified = false // Start unverified.
+	m.Users[user.ID] = user    // Add the user to the map.
+	m.NextID++                 // Increment the next available ID.
+	return user, nil           // Return the created user and no error.
 }
 
 // GetUser retrieves a user by their ID from the store.
 // Returns an error if the user is not found.
-func (m *MockUserStore) GetUser(id int) (model.User, error) {
+func (m *MockUserStore) GetUser(ctx context.Context, id int) (model.User, error) {
 	user, ok := m.Users[id] // Check if the user exists in the map.
 	if !ok {
-		return model.User{}, errors.New("user not found") // Return an error if not found.
+		return model.User{}, store.ErrNotFound // Return an error if not found.
 	}
 	return user, nil // Return the user and no error.
 }
 
 // GetAllUser retrieves all users from the store.
 // Currently returns an empty slice (to be implemented further if needed).
-func (m *MockUserStore) GetAllUser() ([]model.User, error) {
+func (m *MockUserStore) GetAllUser(ctx context.Context) ([]model.User, error) {
 	return []model.User{}, nil // Return an empty slice and no error.
 }
 
+// ListUsers retrieves a page of users from the store.
+// Filtering, ordering and cursors are delegated to store.PaginateUsers.
+func (m *MockUserStore) ListUsers(ctx context.Context, opts store.ListOptions) (store.ListResult, error) {
+	users := make([]model.User, 0, len(m.Users))
+	for _, user := range m.Users {
+		users = append(users, user) // Collect all users from the map.
+	}
+	return store.PaginateUsers(users, opts)
+}
+
 // UpdateUser updates an existing user's details in the store.
+// Returns an error if the user is not found or is not at ifVersion (when non-zero).
+func (m *MockUserStore) UpdateUser(ctx context.Context, id int, user model.User, ifVersion int) (model.User, bool, error) {
+	existing, ok := m.Users[id] // Check if the user exists in the map.
+	if !ok {
+		return model.User{}, false, store.ErrNotFound // Return an error if not found.
+	}
+	if ifVersion != 0 && existing.Version != ifVersion {
+		return model.User{}, false, store.ErrVersionMismatch // Return an error if someone else wrote first.
+	}
+	if err := m.emailTaken(id, user.Email); err != nil {
+		return model.User{}, false, err // Return a conflict if another user has the email.
+	}
+	user.ID = id                              // Ensure the ID remains unchanged.
+	user.Version = existing.Version + 1       // Bump the version.
+	user.PasswordHash = existing.PasswordHash // Keep the password, which only SetPassword changes.
+
+	// Reset the verification flag for a new email.
+	emailChanged := !strings.EqualFold(existing.Email, user.Email)
+	user.EmailVerified = existing.EmailVerified && !emailChanged
+	m.Users[id] = user             // Update the user in the map.
+	return user, emailChanged, nil // Return the updated user and no error.
+}
+
+// GetUserByEmail retrieves a user by their email, ignoring case.
+// Returns an error if no user has the email.
+func (m *MockUserStore) GetUserByEmail(ctx context.Context, email string) (model.User, error) {
+	for _, user := range m.Users {
+		if strings.EqualFold(user.Email, email) {
+			return user, nil // Return the user holding the email.
+		}
+	}
+	return model.User{}, store.ErrNotFound // Return an error if not found.
+}
+
+// SetPassword replaces the password hash of a user.
 // Returns an error if the user is not found.
-func (m *MockUserStore) UpdateUser(id int, user model.User) (model.User, error) {
-	_, ok := m.Users[id] // Check if the user exists in the map.
+func (m *MockUserStore) SetPassword(ctx context.Context, id int, hash string) error {
+	user, ok := m.Users[id] // Check if the user exists in the map.
 	if !ok {
-		return model.User{}, errors.New("user not found") // Return an error if not found.
+		return store.ErrNotFound // Return an error if not found.
+	}
+	user.PasswordHash = hash // Replace the hash without bumping the version.
+	m.Users[id] = user       // Save the user in the map.
+	return nil               // Return no error.
+}
+
+// VerifyEmail marks a user's email as verified if it is still email.
+// Returns an error if the user is not found or changed their email.
+func (m *MockUserStore) VerifyEmail(ctx context.Context, id int, email string) (model.User, error) {
+	user, ok := m.Users[id] // Check if the user exists in the map.
+	if !ok || !strings.EqualFold(user.Email, email) {
+		return model.User{}, store.ErrNotFound // Return an error if not found.
 	}
-	user.ID = id       // Ensure the ID remains unchanged.
-	m.Users[id] = user // Update the user in the map.
-	return user, nil   // Return the updated user and no error.
+	user.EmailVerified = true // Mark the email as verified.
+	user.Version++            // Bump the version.
+	m.Users[id] = user        // Save the user in the map.
+	return user, nil          // Return the verified user and no error.
 }
 
 // DeleteUser removes a user from the store by their ID.
-// Returns an error if the user is not found.
-func (m *MockUserStore) DeleteUser(id int) error {
-	if _, ok := m.Users[id]; !ok { // Check if the user exists in the map.
-		return errors.New("user not found") // Return an error if not found.
+// Returns an error if the user is not found or is not at ifVersion (when non-zero).
+func (m *MockUserStore) DeleteUser(ctx context.Context, id int, ifVersion int) error {
+	existing, ok := m.Users[id] // Check if the user exists in the map.
+	if !ok {
+		return store.ErrNotFound // Return an error if not found.
+	}
+	if ifVersion != 0 && existing.Version != ifVersion {
+		return store.ErrVersionMismatch // Return an error if someone else wrote first.
 	}
 	delete(m.Users, id) // Remove the user from the map.
 	return nil          // Return no error.
 }
+
+// emailTaken returns a conflict error if a user other than id already has the
+// email, compared case-insensitively like the real stores.
+func (m *MockUserStore) emailTaken(id int, email string) error {
+	for _, user := range m.Users {
+		if user.ID != id && strings.EqualFold(user.Email, email) {
+			return &store.ConflictError{Field: "email", Value: email, ExistingID: user.ID}
+		}
+	}
+	return nil
+}
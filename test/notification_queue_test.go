@@ -0,0 +1,180 @@
+package test
+
+import (
+	"Curd/handler"
+	"Curd/model"
+	"Curd/notification"
+	"Curd/router"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// flakyNotifier fails its first Failures deliveries and then succeeds.
+type flakyNotifier struct {
+	Failures int32
+	calls    atomic.Int32
+}
+
+// Notify fails until the configured number of failures has been reached.
+func (f *flakyNotifier) Notify(ctx context.Context, event notification.Event) error {
+	if f.calls.Add(1) <= f.Failures {
+		return errors.New("temporary failure")
+	}
+	return nil
+}
+
+// testQueueConfig retries quickly so tests do not wait on real backoff delays.
+var testQueueConfig = notification.QueueConfig{Workers: 2, Capacity: 16, MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond}
+
+// TestQueue_RetriesUntilDelivered tests that transient failures are retried with backoff.
+func TestQueue_RetriesUntilDelivered(t *testing.T) {
+	flaky := &flakyNotifier{Failures: 2}
+	deadLetters := notification.NewMemoryDeadLetters()
+	queue := notification.NewQueue(map[string]notification.Notifier{"flaky": flaky}, deadLetters, testQueueConfig)
+
+	queue.Notify(context.Background(), notification.Event{Type: notification.EventUserCreated, User: model.User{ID: 1}})
+	if err := queue.Close(context.Background()); err != nil {
+		t.Fatalf("failed to drain queue: %v", err)
+	}
+
+	// Assert that the third attempt succeeded and nothing was dead-lettered.
+	if got := flaky.calls.Load(); got != 3 {
+		t.Errorf("expected 3 attempts, got %d", got)
+	}
+	if letters, _ := deadLetters.List(); len(letters) != 0 {
+		t.Errorf("expected no dead letters, got %+v", letters)
+	}
+}
+
+// TestQueue_DeadLetterReplay tests that exhausted deliveries are listed and can be replayed over HTTP.
+func TestQueue_DeadLetterReplay(t *testing.T) {
+	flaky := &flakyNotifier{Failures: 3}
+	queue := notification.NewQueue(map[string]notification.Notifier{"flaky": flaky}, notification.NewMemoryDeadLetters(), testQueueConfig)
+	defer queue.Close(context.Background())
+	server := router.NewRouter(&handler.UserHandler{Store: NewMockUserStore()}, router.WithDeadLetters(&handler.DeadLetterHandler{Queue: queue}))
+
+	queue.Notify(context.Background(), notification.Event{Type: notification.EventUserCreated, User: model.User{ID: 1}})
+
+	// Wait for the delivery to exhaust its attempts and land in the dead-letter list.
+	var letters []notification.DeadLetter
+	for deadline := time.Now().Add(time.Second); len(letters) == 0 && time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/dead-letters", nil))
+		json.NewDecoder(w.Body).Decode(&letters)
+	}
+	if len(letters) != 1 || letters[0].Target != "flaky" || letters[0].Attempts != 3 {
+		t.Fatalf("expected one dead letter for flaky after 3 attempts, got %+v", letters)
+	}
+
+	// Replay it; the notifier now succeeds.
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/admin/dead-letters/"+letters[0].ID+"/replay", nil))
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("expected 202 Accepted, got %d", w.Code)
+	}
+	for deadline := time.Now().Add(time.Second); flaky.calls.Load() < 4 && time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+	}
+	if got := flaky.calls.Load(); got != 4 {
+		t.Errorf("expected the replay to make a 4th attempt, got %d attempts", got)
+	}
+}
+
+// blockingNotifier blocks every delivery until release is closed.
+type blockingNotifier struct {
+	release chan struct{}
+}
+
+// Notify waits for the release.
+func (b blockingNotifier) Notify(ctx context.Context, event notification.Event) error {
+	<-b.release
+	return nil
+}
+
+// TestQueue_FullDeadLetters tests that events overflowing a full queue are
+// dead-lettered without reporting an error, so callers do not send them again.
+func TestQueue_FullDeadLetters(t *testing.T) {
+	blocking := blockingNotifier{release: make(chan struct{})}
+	deadLetters := notification.NewMemoryDeadLetters()
+	queue := notification.NewQueue(map[string]notification.Notifier{"blocking": blocking}, deadLetters, notification.QueueConfig{Workers: 1, Capacity: 1, MaxAttempts: 1})
+
+	// One event keeps the worker busy, one fills the queue, and the rest overflow.
+	for id := 1; id <= 4; id++ {
+		if err := queue.Notify(context.Background(), notification.Event{Type: notification.EventUserCreated, User: model.User{ID: id}}); err != nil {
+			t.Errorf("event %d: expected no error, got %v", id, err)
+		}
+		time.Sleep(5 * time.Millisecond) // Let the worker pick up the first event.
+	}
+	close(blocking.release)
+	if err := queue.Close(context.Background()); err != nil {
+		t.Fatalf("failed to drain queue: %v", err)
+	}
+
+	if letters, _ := deadLetters.List(); len(letters) != 2 {
+		t.Errorf("expected the 2 overflowing events dead-lettered, got %+v", letters)
+	}
+}
+
+// TestQueue_Synchronous tests that the synchronous notifier returns only once
+// every target has the event or it was dead-lettered.
+func TestQueue_Synchronous(t *testing.T) {
+	flaky := &flakyNotifier{Failures: 2}
+	broken := &flakyNotifier{Failures: 10}
+	deadLetters := notification.NewMemoryDeadLetters()
+	queue := notification.NewQueue(map[string]notification.Notifier{"flaky": flaky, "broken": broken}, deadLetters, testQueueConfig)
+	defer queue.Close(context.Background())
+
+	if err := queue.Synchronous().Notify(context.Background(), notification.Event{Type: notification.EventUserCreated, User: model.User{ID: 1}}); err != nil {
+		t.Fatalf("expected the event to settle, got %v", err)
+	}
+	if got := flaky.calls.Load(); got != 3 {
+		t.Errorf("expected the retried delivery to have finished, got %d attempts", got)
+	}
+	if letters, _ := deadLetters.List(); len(letters) != 1 || letters[0].Target != "broken" {
+		t.Errorf("expected the failing target dead-lettered before returning, got %+v", letters)
+	}
+
+	// A caller that gives up is told to send the event again.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := queue.Synchronous().Notify(ctx, notification.Event{Type: notification.EventUserUpdated}); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
+// TestFileDeadLetters_SaveFailure tests that a dead letter that cannot be
+// written to the file is neither added nor removed in memory either.
+func TestFileDeadLetters_SaveFailure(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "dead-letters")
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	deadLetters, err := notification.NewFileDeadLetters(filepath.Join(dir, "letters.json"))
+	if err != nil {
+		t.Fatalf("failed to open dead letters: %v", err)
+	}
+	if err := deadLetters.Add(notification.DeadLetter{ID: "kept", Target: "email"}); err != nil {
+		t.Fatalf("failed to add dead letter: %v", err)
+	}
+
+	// Without its directory, the file can no longer be written.
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := deadLetters.Add(notification.DeadLetter{ID: "lost", Target: "email"}); err == nil {
+		t.Error("expected adding to fail")
+	}
+	if _, err := deadLetters.Remove("kept"); err == nil {
+		t.Error("expected removing to fail")
+	}
+	if letters, _ := deadLetters.List(); len(letters) != 1 || letters[0].ID != "kept" {
+		t.Errorf("expected only the saved letter, got %+v", letters)
+	}
+}
@@ -0,0 +1,157 @@
+package test
+
+import (
+	"Curd/auth"
+	"Curd/authz"
+	"Curd/config"
+	"Curd/handler"
+	"Curd/model"
+	"Curd/router"
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// TestPassword_Hash tests that hashes verify only their own password and are salted.
+func TestPassword_Hash(t *testing.T) {
+	hash := auth.HashPassword("correct horse")
+	if !strings.HasPrefix(hash, "$argon2id$") || hash == auth.HashPassword("correct horse") {
+		t.Fatalf("expected a salted argon2id hash, got %q", hash)
+	}
+	if ok, err := auth.VerifyPassword(hash, "correct horse"); !ok || err != nil {
+		t.Errorf("expected the password to verify, got %v, %v", ok, err)
+	}
+	if ok, _ := auth.VerifyPassword(hash, "wrong horse"); ok {
+		t.Error("expected a wrong password to be rejected")
+	}
+	if ok, _ := auth.VerifyPassword("", ""); ok {
+		t.Error("expected a user without a password to be rejected")
+	}
+}
+
+// TestPassword_LoginFlow tests setting and changing a password, logging in
+// with it and using the session token, and that the hash is never shown or logged.
+func TestPassword_LoginFlow(t *testing.T) {
+	users := NewMockUserStore()
+	policy, err := authz.NewPolicy(config.Default().Authz.Roles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lockout := auth.NewLockout(5, time.Minute)
+	issuer := &auth.TokenIssuer{Secret: []byte(testSecret), Issuer: "https://issuer.test/", Audience: "curd", TTL: time.Hour, Roles: []string{"user"}}
+	var logs bytes.Buffer
+	server := router.NewRouter(&handler.UserHandler{Store: users, Policy: policy, Lockout: lockout},
+		router.WithLogin(&handler.LoginHandler{Store: users, Issuer: issuer, Lockout: lockout}),
+		router.WithAuthenticator("Bearer", newTestVerifier(t, nil)),
+		router.WithLogger(slog.New(slog.NewJSONHandler(&logs, nil))),
+	)
+	admin := "Bearer " + signToken(t, jwt.SigningMethodHS256, testSecret, "", nil)
+	do := func(method, path, authorization, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", authorization)
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := do(http.MethodPost, "/users", admin, `{"name": "Alice", "email": "alice@example.com"}`); w.Code != http.StatusCreated {
+		t.Fatalf("expected 201 Created, got %d: %s", w.Code, w.Body)
+	}
+
+	// The first password needs no current one; later changes do.
+	if w := do(http.MethodPost, "/users/1/password", admin, `{"new_password": "short"}`); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422 for a short password, got %d", w.Code)
+	}
+	if w := do(http.MethodPost, "/users/1/password", admin, `{"new_password": "first password"}`); w.Code != http.StatusNoContent {
+		t.Fatalf("expected 204 No Content, got %d: %s", w.Code, w.Body)
+	}
+	if w := do(http.MethodPost, "/users/1/password", admin, `{"new_password": "second password"}`); w.Code != http.StatusForbidden {
+		t.Errorf("expected 403 without the current password, got %d", w.Code)
+	}
+	if w := do(http.MethodPost, "/users/1/password", admin, `{"old_password": "first password", "new_password": "second password"}`); w.Code != http.StatusNoContent {
+		t.Fatalf("expected 204 No Content, got %d: %s", w.Code, w.Body)
+	}
+
+	// Logging in issues a bearer token for the user's own record.
+	w := do(http.MethodPost, "/auth/login", "", `{"email": "ALICE@example.com", "password": "second password"}`)
+	var login struct {
+		AccessToken string `json:"access_token"`
+		TokenType   string `json:"token_type"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	json.NewDecoder(w.Body).Decode(&login)
+	if w.Code != http.StatusOK || login.TokenType != "Bearer" || login.ExpiresIn != 3600 || w.Header().Get("Cache-Control") != "no-store" {
+		t.Fatalf("expected a session token, got %d: %+v", w.Code, login)
+	}
+	for _, line := range strings.Split(strings.TrimSpace(logs.String()), "\n") {
+		var entry map[string]any
+		if json.Unmarshal([]byte(line), &entry) != nil || entry["audit"] != true {
+			continue
+		}
+		if entry["user_id"] != float64(1) || strings.Contains(line, "alice@example.com") || strings.Contains(line, "argon2") {
+			t.Errorf("expected password changes and logins to be logged by user ID only, got %s", line)
+		}
+	}
+	session := "Bearer " + login.AccessToken
+	w = do(http.MethodGet, "/users/1", session, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected the session to read its own user, got %d", w.Code)
+	}
+	if strings.Contains(w.Body.String(), "argon2") || strings.Contains(w.Body.String(), "password") {
+		t.Errorf("expected the hash never to be serialized, got %s", w.Body)
+	}
+	if w := do(http.MethodGet, "/users", session, ""); w.Code != http.StatusForbidden {
+		t.Errorf("expected the session not to list users, got %d", w.Code)
+	}
+
+	// Wrong passwords and unknown emails get the same answer.
+	for _, body := range []string{`{"email": "alice@example.com", "password": "first password"}`, `{"email": "nobody@example.com", "password": "x"}`} {
+		w := do(http.MethodPost, "/auth/login", "", body)
+		var problem handler.Problem
+		json.NewDecoder(w.Body).Decode(&problem)
+		if w.Code != http.StatusUnauthorized || problem.Detail != "The email or password is incorrect." {
+			t.Errorf("expected 401 for %s, got %d: %+v", body, w.Code, problem)
+		}
+	}
+}
+
+// TestPassword_Lockout tests that repeated failures lock the account, even
+// against the right password, until the lockout expires.
+func TestPassword_Lockout(t *testing.T) {
+	users := NewMockUserStore()
+	users.Users[1] = model.User{ID: 1, Name: "Alice", Email: "alice@example.com", Version: 1, PasswordHash: auth.HashPassword("right password")}
+	users.NextID = 2
+	lockout := auth.NewLockout(5, time.Minute)
+	issuer := &auth.TokenIssuer{Secret: []byte(testSecret), Issuer: "https://issuer.test/", Audience: "curd", TTL: time.Hour}
+	server := router.NewRouter(&handler.UserHandler{Store: users},
+		router.WithLogin(&handler.LoginHandler{Store: users, Issuer: issuer, Lockout: lockout}),
+	)
+	login := func(password string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/auth/login", strings.NewReader(`{"email": "alice@example.com", "password": "`+password+`"}`))
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+		return w
+	}
+
+	for i := 0; i < 5; i++ {
+		if w := login("guess"); w.Code != http.StatusUnauthorized {
+			t.Fatalf("attempt %d: expected 401, got %d", i+1, w.Code)
+		}
+	}
+	w := login("right password")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Errorf("expected 429 with Retry-After once locked, got %d", w.Code)
+	}
+
+	// Another account is unaffected.
+	if _, locked := lockout.Locked("bob@example.com"); locked {
+		t.Error("expected only the guessed account to be locked")
+	}
+}
@@ -0,0 +1,43 @@
+package test
+
+import (
+	"Curd/config"
+	"Curd/handler"
+	"Curd/router"
+	"Curd/tracing"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// TestTracing tests that request and store spans continue the trace of an incoming traceparent header.
+func TestTracing(t *testing.T) {
+	if _, err := tracing.Setup(context.Background(), config.TracingConfig{Exporter: config.ExporterNone}); err != nil {
+		t.Fatal(err)
+	}
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(previous)
+
+	server := router.NewRouter(&handler.UserHandler{Store: tracing.TraceStore(NewMockUserStore())}, router.WithTracing())
+	req := httptest.NewRequest(http.MethodGet, "/users/7", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	server.ServeHTTP(httptest.NewRecorder(), req)
+
+	names := map[string]bool{}
+	for _, span := range recorder.Ended() {
+		names[span.Name()] = true
+		if got := span.SpanContext().TraceID().String(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+			t.Errorf("span %s: expected the incoming trace ID, got %s", span.Name(), got)
+		}
+	}
+	if !names["GET /users/"] || !names["store.GetUser"] {
+		t.Errorf("expected request and store spans, got %v", names)
+	}
+}
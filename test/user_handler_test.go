@@ -3,12 +3,18 @@ package test
 import (
 	"Curd/handler"
 	"Curd/model"
+	"Curd/notification"
 	"Curd/router"
+	"Curd/store"
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 )
 
 // setupMockServer initializes a mock server with a mock user store and returns the router.
@@ -69,3 +75,422 @@ func TestGetUser_NotFound(t *testing.T) {
 		t.Errorf("expected 404 Not Found, got %d", w.Code)
 	}
 }
+
+// TestGetAllUser_Pagination tests that users are returned in pages linked by next_cursor.
+func TestGetAllUser_Pagination(t *testing.T) {
+	mockStore := NewMockUserStore() // Seed the store directly with five users.
+	for _, name := range []string{"Erin", "Dave", "Carol", "Bob", "Alice"} {
+		mockStore.CreateUser(context.Background(), model.User{Name: name, Email: name + "@example.com"})
+	}
+	server := router.NewRouter(&handler.UserHandler{Store: mockStore})
+
+	// Walk the pages sorted by name, two users at a time.
+	var names []string
+	cursor := ""
+	for page := 0; page < 5; page++ {
+		req := httptest.NewRequest(http.MethodGet, "/users?limit=2&sort=name&cursor="+cursor, nil)
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200 OK, got %d", w.Code)
+		}
+
+		var resp struct {
+			Users      []model.User `json:"users"`
+			NextCursor string       `json:"next_cursor"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		for _, u := range resp.Users {
+			names = append(names, u.Name)
+		}
+		if resp.NextCursor == "" {
+			break
+		}
+		cursor = resp.NextCursor
+	}
+
+	// Assert that every user was returned exactly once, in name order.
+	want := []string{"Alice", "Bob", "Carol", "Dave", "Erin"}
+	if len(names) != len(want) {
+		t.Fatalf("expected %v, got %v", want, names)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Errorf("expected %v, got %v", want, names)
+			break
+		}
+	}
+}
+
+// TestGetAllUser_InvalidSort tests that an unsupported sort field is rejected (400 Bad Request).
+func TestGetAllUser_InvalidSort(t *testing.T) {
+	server := setupMockServer() // Set up the mock server.
+
+	req := httptest.NewRequest(http.MethodGet, "/users?sort=password", nil)
+	w := httptest.NewRecorder()
+
+	server.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 Bad Request, got %d", w.Code)
+	}
+}
+
+// unavailableStore is a user store whose every lookup fails with store.ErrUnavailable.
+type unavailableStore struct{ *MockUserStore }
+
+// GetUser simulates a database outage.
+func (unavailableStore) GetUser(ctx context.Context, id int) (model.User, error) {
+	return model.User{}, &store.Error{Op: "GetUser", Kind: store.ErrUnavailable}
+}
+
+// TestGetUser_StoreUnavailable tests that a store outage is reported as 503, not 404.
+func TestGetUser_StoreUnavailable(t *testing.T) {
+	server := router.NewRouter(&handler.UserHandler{Store: unavailableStore{NewMockUserStore()}})
+
+	req := httptest.NewRequest(http.MethodGet, "/users/1", nil)
+	w := httptest.NewRecorder()
+
+	server.ServeHTTP(w, req)
+
+	// Assert that the response status code is 503 Service Unavailable.
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 Service Unavailable, got %d", w.Code)
+	}
+}
+
+// slowStore is a user store whose lookups block until the request context is done.
+type slowStore struct{ *MockUserStore }
+
+// GetUser waits for the request deadline instead of returning a user.
+func (slowStore) GetUser(ctx context.Context, id int) (model.User, error) {
+	<-ctx.Done()
+	return model.User{}, ctx.Err()
+}
+
+// TestGetUser_RequestTimeout tests that the handler's RequestTimeout cancels slow store calls (503).
+func TestGetUser_RequestTimeout(t *testing.T) {
+	server := router.NewRouter(&handler.UserHandler{
+		Store:          slowStore{NewMockUserStore()},
+		RequestTimeout: 10 * time.Millisecond,
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/users/1", nil)
+	w := httptest.NewRecorder()
+
+	server.ServeHTTP(w, req)
+
+	// Assert that the response status code is 503 Service Unavailable.
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 Service Unavailable, got %d", w.Code)
+	}
+}
+
+// TestCreateUser_Notifies tests that creating a user announces it through the injected notifier.
+func TestCreateUser_Notifies(t *testing.T) {
+	notifier := &MockNotifier{}
+	server := router.NewRouter(&handler.UserHandler{Store: NewMockUserStore(), Notifier: notifier})
+
+	body, _ := json.Marshal(model.User{Name: "Alice", Email: "alice@example.com"})
+	req := httptest.NewRequest(http.MethodPost, "/users", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+
+	server.ServeHTTP(w, req)
+
+	// Assert that exactly one user.created event was delivered for Alice.
+	events := notifier.Received()
+	if len(events) != 1 || events[0].Type != notification.EventUserCreated || events[0].User.Name != "Alice" {
+		t.Errorf("expected one user.created event for Alice, got %+v", events)
+	}
+}
+
+// patchUser sends a PATCH request with the given media type and returns the recorder.
+func patchUser(server http.Handler, path, contentType, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPatch, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+	return w
+}
+
+// TestPatchUser tests JSON Merge Patch and JSON Patch updates against a stored user.
+func TestPatchUser(t *testing.T) {
+	mockStore := NewMockUserStore()
+	mockStore.CreateUser(context.Background(), model.User{Name: "Alice", Email: "alice@example.com"})
+	server := router.NewRouter(&handler.UserHandler{Store: mockStore})
+
+	// A merge patch that only sets the name must leave the email untouched.
+	w := patchUser(server, "/users/1", "application/merge-patch+json", `{"name":"Alicia"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("merge patch: expected 200 OK, got %d: %s", w.Code, w.Body)
+	}
+	if u := mockStore.Users[1]; u.Name != "Alicia" || u.Email != "alice@example.com" {
+		t.Errorf("merge patch: unexpected user %+v", u)
+	}
+
+	// A JSON Patch can test and replace individual fields.
+	w = patchUser(server, "/users/1", "application/json-patch+json",
+		`[{"op":"test","path":"/name","value":"Alicia"},{"op":"replace","path":"/email","value":"alicia@example.com"}]`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("json patch: expected 200 OK, got %d: %s", w.Code, w.Body)
+	}
+	if u := mockStore.Users[1]; u.Name != "Alicia" || u.Email != "alicia@example.com" {
+		t.Errorf("json patch: unexpected user %+v", u)
+	}
+}
+
+// TestPatchUser_Rejected tests that unsupported formats and invalid results are refused.
+func TestPatchUser_Rejected(t *testing.T) {
+	mockStore := NewMockUserStore()
+	mockStore.CreateUser(context.Background(), model.User{Name: "Alice", Email: "alice@example.com"})
+	server := router.NewRouter(&handler.UserHandler{Store: mockStore})
+
+	tests := []struct {
+		contentType string
+		body        string
+		want        int
+	}{
+		{"application/json", `{"name":"x"}`, http.StatusUnsupportedMediaType},              // Plain JSON is not a patch format.
+		{"application/merge-patch+json", `{"email":null}`, http.StatusUnprocessableEntity}, // Removing the email leaves an invalid user.
+		{"application/merge-patch+json", `{"admin":true}`, http.StatusUnprocessableEntity}, // Unknown fields are rejected.
+		{"application/json-patch+json", `[{"op":"test","path":"/name","value":"Bob"}]`, http.StatusUnprocessableEntity},
+	}
+	for _, tt := range tests {
+		if w := patchUser(server, "/users/1", tt.contentType, tt.body); w.Code != tt.want {
+			t.Errorf("%s %s: expected %d, got %d", tt.contentType, tt.body, tt.want, w.Code)
+		}
+	}
+	if u := mockStore.Users[1]; u.Name != "Alice" || u.Email != "alice@example.com" {
+		t.Errorf("expected the user to be unchanged, got %+v", u)
+	}
+}
+
+// TestConditionalRequests tests ETag, If-None-Match and If-Match handling on a user resource.
+func TestConditionalRequests(t *testing.T) {
+	mockStore := NewMockUserStore()
+	mockStore.CreateUser(context.Background(), model.User{Name: "Alice", Email: "alice@example.com"})
+	server := router.NewRouter(&handler.UserHandler{Store: mockStore})
+
+	send := func(method, ifHeader, value, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/users/1", strings.NewReader(body))
+		if ifHeader != "" {
+			req.Header.Set(ifHeader, value)
+		}
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+		return w
+	}
+
+	// GET returns a strong ETag and honors If-None-Match.
+	w := send(http.MethodGet, "", "", "")
+	tag := w.Header().Get("ETag")
+	if tag != `"1"` {
+		t.Fatalf(`expected ETag "1", got %q`, tag)
+	}
+	if w = send(http.MethodGet, "If-None-Match", tag, ""); w.Code != http.StatusNotModified {
+		t.Errorf("expected 304 Not Modified, got %d", w.Code)
+	}
+
+	// If-None-Match compares weakly, so a tag weakened by a cache still
+	// revalidates; If-Match compares strongly, so it cannot authorize a write.
+	if w = send(http.MethodGet, "If-None-Match", `"0", W/`+tag, ""); w.Code != http.StatusNotModified {
+		t.Errorf("expected 304 Not Modified for a weak tag, got %d", w.Code)
+	}
+	if w = send(http.MethodGet, "If-None-Match", `W/"2"`, ""); w.Code != http.StatusOK {
+		t.Errorf("expected 200 OK for a weak tag of another version, got %d", w.Code)
+	}
+	if w = send(http.MethodPut, "If-Match", "W/"+tag, `{"name":"Alice","email":"alice@example.com"}`); w.Code != http.StatusPreconditionFailed {
+		t.Errorf("expected 412 Precondition Failed for a weak If-Match, got %d", w.Code)
+	}
+
+	// The first PUT with the current ETag wins and bumps the version...
+	if w = send(http.MethodPut, "If-Match", tag, `{"name":"Alicia","email":"alice@example.com"}`); w.Code != http.StatusOK {
+		t.Fatalf("expected 200 OK, got %d", w.Code)
+	}
+	if got := w.Header().Get("ETag"); got != `"2"` {
+		t.Errorf(`expected new ETag "2", got %q`, got)
+	}
+
+	// ...so a second editor still holding the old ETag is refused.
+	if w = send(http.MethodPut, "If-Match", tag, `{"name":"Ally","email":"alice@example.com"}`); w.Code != http.StatusPreconditionFailed {
+		t.Errorf("PUT: expected 412 Precondition Failed, got %d", w.Code)
+	}
+	if w = send(http.MethodDelete, "If-Match", tag, ""); w.Code != http.StatusPreconditionFailed {
+		t.Errorf("DELETE: expected 412 Precondition Failed, got %d", w.Code)
+	}
+	if u := mockStore.Users[1]; u.Name != "Alicia" {
+		t.Errorf("expected the first write to be kept, got %+v", u)
+	}
+}
+
+// TestStore_ConditionalUpdate tests that the in-memory store enforces versions itself.
+func TestStore_ConditionalUpdate(t *testing.T) {
+	s, _ := store.NewUserStore()
+	ctx := context.Background()
+	created, _ := s.CreateUser(ctx, model.User{Name: "Alice", Email: "alice@example.com"})
+
+	if _, _, err := s.UpdateUser(ctx, created.ID, model.User{Name: "A"}, created.Version); err != nil {
+		t.Fatalf("expected first conditional update to succeed, got %v", err)
+	}
+	if _, _, err := s.UpdateUser(ctx, created.ID, model.User{Name: "B"}, created.Version); !errors.Is(err, store.ErrVersionMismatch) {
+		t.Errorf("expected ErrVersionMismatch, got %v", err)
+	}
+	if err := s.DeleteUser(ctx, created.ID, created.Version); !errors.Is(err, store.ErrVersionMismatch) {
+		t.Errorf("expected ErrVersionMismatch, got %v", err)
+	}
+}
+
+// TestStore_GetAllUserEmpty tests that an empty in-memory store lists no users rather than failing.
+func TestStore_GetAllUserEmpty(t *testing.T) {
+	s, _ := store.NewUserStore()
+	users, err := s.GetAllUser(context.Background())
+	if err != nil || users == nil || len(users) != 0 {
+		t.Errorf("expected an empty list and no error, got %v, %v", users, err)
+	}
+}
+
+// TestCreateUser_Validation tests that every invalid field is reported in a structured 422 body.
+func TestCreateUser_Validation(t *testing.T) {
+	server := setupMockServer() // Set up the mock server.
+
+	req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(`{"name":"   ","email":"not-an-email"}`))
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+
+	// Assert that the response is 422 and names both fields.
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 Unprocessable Entity, got %d", w.Code)
+	}
+	var resp struct {
+		Errors []struct {
+			Field string `json:"field"`
+			Code  string `json:"code"`
+		} `json:"errors"`
+	}
+	json.NewDecoder(w.Body).Decode(&resp)
+	got := map[string]string{}
+	for _, fe := range resp.Errors {
+		got[fe.Field] = fe.Code
+	}
+	if got["name"] != "required" || got["email"] != "email" {
+		t.Errorf("expected name/required and email/email errors, got %+v", resp.Errors)
+	}
+}
+
+// TestCreateUser_UnknownField tests that unknown JSON fields are rejected rather than ignored.
+func TestCreateUser_UnknownField(t *testing.T) {
+	server := setupMockServer() // Set up the mock server.
+
+	req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(`{"name":"Alice","email":"alice@example.com","admin":true}`))
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+
+	if w.Code != http.StatusUnprocessableEntity || !strings.Contains(w.Body.String(), `"admin"`) {
+		t.Errorf("expected 422 naming the admin field, got %d: %s", w.Code, w.Body)
+	}
+}
+
+// TestCreateUser_Normalization tests that names are trimmed and emails lowercased before storing.
+func TestCreateUser_Normalization(t *testing.T) {
+	mockStore := NewMockUserStore()
+	server := router.NewRouter(&handler.UserHandler{Store: mockStore})
+
+	req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(`{"name":"  Alice ","email":" Alice@Example.COM "}`))
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201 Created, got %d: %s", w.Code, w.Body)
+	}
+	if u := mockStore.Users[1]; u.Name != "Alice" || u.Email != "alice@example.com" {
+		t.Errorf("expected normalized user, got %+v", u)
+	}
+}
+
+// TestProblemResponse tests that errors are rendered as RFC 7807 problem details.
+func TestProblemResponse(t *testing.T) {
+	server := setupMockServer() // Set up the mock server.
+
+	req := httptest.NewRequest(http.MethodGet, "/users/999", nil)
+	req.Header.Set("X-Request-ID", "req-123")
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+
+	if ct := w.Header().Get("Content-Type"); ct != "application/problem+json" {
+		t.Fatalf("expected application/problem+json, got %q", ct)
+	}
+	var problem handler.Problem
+	if err := json.NewDecoder(w.Body).Decode(&problem); err != nil {
+		t.Fatalf("failed to decode problem: %v", err)
+	}
+	want := handler.Problem{Type: "about:blank", Title: "Not Found", Status: 404, Detail: "User not found", Instance: "/users/999", RequestID: "req-123"}
+	if problem.Type != want.Type || problem.Title != want.Title || problem.Status != want.Status ||
+		problem.Detail != want.Detail || problem.Instance != want.Instance || problem.RequestID != want.RequestID {
+		t.Errorf("expected %+v, got %+v", want, problem)
+	}
+}
+
+// TestProblemResponse_UnknownRoute tests that the default route also returns a problem.
+func TestProblemResponse_UnknownRoute(t *testing.T) {
+	server := setupMockServer() // Set up the mock server.
+
+	req := httptest.NewRequest(http.MethodPost, "/users/1", nil)
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound || w.Header().Get("Content-Type") != "application/problem+json" {
+		t.Errorf("expected a 404 problem, got %d %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	if w.Header().Get("X-Request-ID") == "" {
+		t.Error("expected a generated X-Request-ID header")
+	}
+}
+
+// TestCreateUser_DuplicateEmail tests that a second user with the same email, in any case, gets a 409 naming the first.
+func TestCreateUser_DuplicateEmail(t *testing.T) {
+	mockStore := NewMockUserStore()
+	mockStore.CreateUser(context.Background(), model.User{Name: "Alice", Email: "alice@example.com"})
+	mockStore.CreateUser(context.Background(), model.User{Name: "Bob", Email: "bob@example.com"})
+	server := router.NewRouter(&handler.UserHandler{Store: mockStore})
+
+	for _, tc := range []struct{ method, path string }{
+		{http.MethodPost, "/users"},
+		{http.MethodPut, "/users/2"},
+	} {
+		req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(`{"name":"Eve","email":"ALICE@example.com"}`))
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+
+		if w.Code != http.StatusConflict {
+			t.Fatalf("%s %s: expected 409 Conflict, got %d: %s", tc.method, tc.path, w.Code, w.Body)
+		}
+		var problem handler.Problem
+		json.NewDecoder(w.Body).Decode(&problem)
+		if problem.Existing != "/users/1" {
+			t.Errorf("%s %s: expected the problem to name /users/1, got %+v", tc.method, tc.path, problem)
+		}
+	}
+}
+
+// TestStore_UniqueEmail tests that the in-memory store compares emails case-insensitively and frees them on delete.
+func TestStore_UniqueEmail(t *testing.T) {
+	s, _ := store.NewUserStore()
+	ctx := context.Background()
+	alice, _ := s.CreateUser(ctx, model.User{Name: "Alice", Email: "alice@example.com"})
+
+	var conflict *store.ConflictError
+	if _, err := s.CreateUser(ctx, model.User{Name: "Eve", Email: "Alice@Example.com"}); !errors.As(err, &conflict) || conflict.ExistingID != alice.ID {
+		t.Fatalf("expected a conflict with user %d, got %v", alice.ID, err)
+	}
+	if _, _, err := s.UpdateUser(ctx, alice.ID, model.User{Name: "Alice", Email: "ALICE@example.com"}, 0); err != nil {
+		t.Errorf("expected a user to keep its own email, got %v", err)
+	}
+	if err := s.DeleteUser(ctx, alice.ID, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.CreateUser(ctx, model.User{Name: "Eve", Email: "alice@example.com"}); err != nil {
+		t.Errorf("expected the email to be free after delete, got %v", err)
+	}
+}
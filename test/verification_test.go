@@ -0,0 +1,335 @@
+package test
+
+import (
+	"Curd/auth"
+	"Curd/handler"
+	"Curd/metrics"
+	"Curd/model"
+	"Curd/notification"
+	"Curd/router"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// syncBuffer is a bytes.Buffer safe for the concurrent writes of background sends.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+// Write appends to the buffer.
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+// String returns what has been written so far.
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// TestVerification_Flow tests that new users are emailed a link that
+// verifies them, that changing the email asks for a new verification, and
+// that the logs only identify users by ID.
+func TestVerification_Flow(t *testing.T) {
+	users := NewMockUserStore()
+	mailer := NewMockMailer()
+	var logs syncBuffer
+	server := router.NewRouter(&handler.UserHandler{Store: users, Verification: &handler.EmailVerification{
+		Tokens: &auth.VerificationTokens{Secret: []byte(testSecret), TTL: time.Hour},
+		URL:    "https://api.example.com/users/verify",
+		Mailer: mailer,
+	}}, router.WithLogger(slog.New(slog.NewJSONHandler(&logs, nil))))
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if method == http.MethodPatch {
+			req.Header.Set("Content-Type", "application/merge-patch+json")
+		}
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+		return w
+	}
+	follow := func(link string) *httptest.ResponseRecorder {
+		u, err := url.Parse(link)
+		if err != nil || u.Host != "api.example.com" {
+			t.Fatalf("expected a link to the configured URL, got %q", link)
+		}
+		return do(http.MethodGet, u.RequestURI(), "")
+	}
+
+	// Creating a user sends a link; the user cannot verify themselves through the body.
+	if w := do(http.MethodPost, "/users", `{"name": "Alice", "email": "alice@example.com", "email_verified": true}`); w.Code != http.StatusCreated {
+		t.Fatalf("expected 201 Created, got %d: %s", w.Code, w.Body)
+	}
+	if users.Users[1].EmailVerified {
+		t.Fatal("expected a new user to be unverified")
+	}
+	first := mailer.Next(t)
+	if first.Email != "alice@example.com" || first.Link == "" {
+		t.Fatalf("expected a link emailed to the user, got %+v", first)
+	}
+
+	// Following the link verifies the email, and following it again is harmless.
+	if w := follow(first.Link); w.Code != http.StatusNoContent {
+		t.Fatalf("expected 204 No Content, got %d: %s", w.Code, w.Body)
+	}
+	if !users.Users[1].EmailVerified {
+		t.Fatal("expected the email to be verified")
+	}
+	if w := follow(first.Link); w.Code != http.StatusNoContent {
+		t.Errorf("expected a second visit to succeed, got %d", w.Code)
+	}
+
+	// Updating other fields keeps the flag and sends nothing.
+	if w := do(http.MethodPut, "/users/1", `{"name": "Alice B", "email": "alice@example.com"}`); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"email_verified":true`) {
+		t.Errorf("expected the user to stay verified, got %d: %s", w.Code, w.Body)
+	}
+	mailer.None(t)
+
+	// Changing the email resets the flag and sends a link for the new address.
+	if w := do(http.MethodPut, "/users/1", `{"name": "Alice B", "email": "alice@example.org"}`); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"email_verified":false`) {
+		t.Fatalf("expected the new email to be unverified, got %d: %s", w.Code, w.Body)
+	}
+	second := mailer.Next(t)
+	if second.Email != "alice@example.org" {
+		t.Fatalf("expected the link sent to the new email, got %s", second.Email)
+	}
+
+	// The old link no longer verifies anything.
+	if w := follow(first.Link); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a link to the old email, got %d", w.Code)
+	}
+	if w := follow(second.Link); w.Code != http.StatusNoContent || !users.Users[1].EmailVerified {
+		t.Errorf("expected the new link to verify the email, got %d", w.Code)
+	}
+
+	// Patching the email asks for verification too.
+	w := do(http.MethodPatch, "/users/1", `{"email": "alice@example.net"}`)
+	if w.Code != http.StatusOK || users.Users[1].EmailVerified {
+		t.Fatalf("expected the patched email to be unverified, got %d: %s", w.Code, w.Body)
+	}
+	if third := mailer.Next(t); third.Email != "alice@example.net" {
+		t.Errorf("expected the link sent to the patched email, got %s", third.Email)
+	}
+
+	for _, line := range strings.Split(strings.TrimSpace(logs.String()), "\n") {
+		var entry map[string]any
+		if json.Unmarshal([]byte(line), &entry) != nil || !strings.Contains(entry["msg"].(string), "verif") {
+			continue
+		}
+		if entry["user_id"] != float64(1) || strings.Contains(line, "alice@") {
+			t.Errorf("expected verifications to be logged by user ID only, got %s", line)
+		}
+	}
+}
+
+// TestVerification_BadLinks tests that forged, expired and foreign links are rejected.
+func TestVerification_BadLinks(t *testing.T) {
+	users := NewMockUserStore()
+	users.Users[1] = model.User{ID: 1, Name: "Alice", Email: "alice@example.com", Version: 1}
+	tokens := &auth.VerificationTokens{Secret: []byte(testSecret), TTL: time.Hour}
+	server := router.NewRouter(&handler.UserHandler{Store: users, Verification: &handler.EmailVerification{Tokens: tokens, Mailer: NewMockMailer()}},
+		router.WithAuthenticator("Bearer", newTestVerifier(t, nil)),
+	)
+
+	expired, _ := (&auth.VerificationTokens{Secret: []byte(testSecret), TTL: -time.Minute}).Issue(users.Users[1])
+	forged, _ := (&auth.VerificationTokens{Secret: []byte(strings.Repeat("x", 32)), TTL: time.Hour}).Issue(users.Users[1])
+	session := signToken(t, jwt.SigningMethodHS256, testSecret, "", nil) // An API token is not a verification token.
+	for name, token := range map[string]string{"expired": expired, "forged": forged, "session": session, "missing": ""} {
+		req := httptest.NewRequest(http.MethodGet, "/users/verify?token="+url.QueryEscape(token), nil)
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400 without credentials, got %d: %s", name, w.Code, w.Body)
+		}
+	}
+	if users.Users[1].EmailVerified {
+		t.Error("expected no bad link to verify the email")
+	}
+
+	// A verification token is not accepted as API credentials either.
+	valid, _ := tokens.Issue(users.Users[1])
+	req := httptest.NewRequest(http.MethodGet, "/users/1", nil)
+	req.Header.Set("Authorization", "Bearer "+valid)
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected a verification token to be rejected by the API, got %d", w.Code)
+	}
+}
+
+// TestDefaultTemplates_VerifyEmail tests that the verification email carries the link.
+func TestDefaultTemplates_VerifyEmail(t *testing.T) {
+	link := "https://api.example.com/users/verify?token=a.b.c&x=1"
+	email, err := notification.DefaultTemplates().RenderData(notification.TemplateVerifyEmail, notification.TemplateData{User: model.User{Name: "Alice", Email: "alice@example.com", Locale: "es"}, Link: link})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if email.Subject != "Confirma tu dirección de correo" || !strings.Contains(email.Text, link) || !strings.Contains(email.HTML, `href="https://api.example.com/users/verify?token=a.b.c&amp;x=1"`) {
+		t.Errorf("expected a localized email with the link, got %+v", email)
+	}
+}
+
+// flakyMailer fails its first Failures sends and then records them like MockMailer.
+type flakyMailer struct {
+	*MockMailer
+	Failures int32
+	calls    atomic.Int32
+}
+
+// Send fails until the configured number of failures has been reached.
+func (m *flakyMailer) Send(ctx context.Context, name string, data notification.TemplateData) error {
+	if m.calls.Add(1) <= m.Failures {
+		return errors.New("temporary failure")
+	}
+	return m.MockMailer.Send(ctx, name, data)
+}
+
+// TestVerification_Queued tests that verification emails sent through the
+// queue are retried and dead-lettered like notifications, that dead letters
+// never hold the link's token, and that the verification target never gets
+// the events broadcast to the others.
+func TestVerification_Queued(t *testing.T) {
+	users := NewMockUserStore()
+	mailer := &flakyMailer{MockMailer: NewMockMailer(), Failures: 3} // Every attempt of the first delivery fails.
+	verification := &handler.EmailVerification{
+		Tokens: &auth.VerificationTokens{Secret: []byte(testSecret), TTL: time.Hour},
+		URL:    "https://api.example.com/users/verify",
+		Mailer: mailer,
+	}
+	other := &flakyNotifier{}
+	deadLetters := notification.NewMemoryDeadLetters()
+	stats := metrics.New()
+	queue := notification.NewQueue(map[string]notification.Notifier{
+		handler.VerificationTarget: notification.TargetOnly(stats.InstrumentNotifier(handler.VerificationTarget, verification)),
+		"other":                    other,
+	}, deadLetters, testQueueConfig)
+	defer queue.Close(context.Background())
+	verification.Notifier = queue.Target(handler.VerificationTarget)
+	server := router.NewRouter(&handler.UserHandler{Store: users, Notifier: queue, Verification: verification})
+
+	req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(`{"name": "Alice", "email": "alice@example.com"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201 Created, got %d: %s", w.Code, w.Body)
+	}
+
+	// Wait for the send to exhaust its attempts and land in the dead letters, without its token.
+	var letters []notification.DeadLetter
+	for deadline := time.Now().Add(time.Second); len(letters) == 0 && time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		letters, _ = deadLetters.List()
+	}
+	if len(letters) != 1 || letters[0].Target != handler.VerificationTarget || letters[0].Event.Type != notification.EventEmailVerification || letters[0].Attempts != 3 {
+		t.Fatalf("expected one dead-lettered verification after 3 attempts, got %+v", letters)
+	}
+	if dump, _ := json.Marshal(letters); strings.Contains(string(dump), "token") {
+		t.Errorf("expected no token in dead letters, got %s", dump)
+	}
+
+	// Replaying it sends a fresh link.
+	if err := queue.Replay(letters[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	if sent := mailer.Next(t); sent.Email != "alice@example.com" || !strings.Contains(sent.Link, "token=") {
+		t.Errorf("expected the replay to email a link, got %+v", sent)
+	}
+
+	// Broadcast events, including those the outbox relay waits on, reach the
+	// other target only; the verification target counts just its own sends.
+	if err := queue.Synchronous().Notify(context.Background(), notification.Event{Type: notification.EventUserUpdated}); err != nil {
+		t.Fatal(err)
+	}
+	if got := other.calls.Load(); got != 2 {
+		t.Errorf("expected the created and updated events for the other target, got %d", got)
+	}
+	w = httptest.NewRecorder()
+	stats.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	for _, want := range []string{
+		`curd_notification_sends_total{channel="verification",outcome="failure"} 3`,
+		`curd_notification_sends_total{channel="verification",outcome="success"} 1`,
+	} {
+		if !strings.Contains(w.Body.String(), want) {
+			t.Errorf("expected %s in the metrics", want)
+		}
+	}
+}
+
+// racingStore is a MockUserStore in which another writer changes the user's
+// email right after every read, and which counts the reads.
+type racingStore struct {
+	*MockUserStore
+	reads int
+}
+
+// GetUser returns the user, then lets the other writer change it.
+func (s *racingStore) GetUser(ctx context.Context, id int) (model.User, error) {
+	s.reads++
+	user, err := s.MockUserStore.GetUser(ctx, id)
+	if err == nil {
+		racer := user
+		racer.Email = "bob@example.com"
+		racer.Version++
+		s.Users[id] = racer
+	}
+	return user, err
+}
+
+// TestVerification_UpdateComparesWrittenEmail tests that updates ask for a
+// new verification only when the write itself changed the email, and that
+// they stay unconditional without a precise If-Match.
+func TestVerification_UpdateComparesWrittenEmail(t *testing.T) {
+	users := &racingStore{MockUserStore: NewMockUserStore()}
+	users.Users[1] = model.User{ID: 1, Name: "Alice", Email: "alice@example.com", Version: 1}
+	mailer := NewMockMailer()
+	server := router.NewRouter(&handler.UserHandler{Store: users, Verification: &handler.EmailVerification{
+		Tokens: &auth.VerificationTokens{Secret: []byte(testSecret), TTL: time.Hour},
+		Mailer: mailer,
+	}})
+	put := func(ifMatch, email string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, "/users/1", strings.NewReader(`{"name": "Alice", "email": "`+email+`"}`))
+		req.Header.Set("Content-Type", "application/json")
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+		return w
+	}
+
+	// An unconditional update reads nothing and emails the new address.
+	if w := put("", "carol@example.com"); w.Code != http.StatusOK {
+		t.Fatalf("expected 200 OK, got %d: %s", w.Code, w.Body)
+	}
+	if sent := mailer.Next(t); sent.Email != "carol@example.com" {
+		t.Errorf("expected a link emailed to the new address, got %+v", sent)
+	}
+	if users.reads != 0 {
+		t.Errorf("expected an unconditional update not to read the user, got %d reads", users.reads)
+	}
+
+	// "*" only needs the user to exist, so the other writer's change does not
+	// fail the update; and as it already set the same email, nothing is sent.
+	if w := put("*", "bob@example.com"); w.Code != http.StatusOK {
+		t.Fatalf("expected 200 OK for If-Match: *, got %d: %s", w.Code, w.Body)
+	}
+	mailer.None(t)
+}
@@ -0,0 +1,46 @@
+package tracing
+
+import (
+	"Curd/notification"
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracedNotifier is a notification.Notifier that wraps every send of the
+// notifier it wraps in a span.
+type tracedNotifier struct {
+	channel string
+	next    notification.Notifier
+}
+
+// TraceNotifier wraps the notifier for a channel (e.g. "fcm" or "email") so
+// every send attempt is a span. The wrapper also forwards health checks when
+// next supports them.
+func TraceNotifier(channel string, next notification.Notifier) notification.Notifier {
+	return &tracedNotifier{channel: channel, next: next}
+}
+
+// Notify sends through the wrapped notifier inside a span.
+func (n *tracedNotifier) Notify(ctx context.Context, event notification.Event) error {
+	ctx, span := tracer().Start(ctx, "notification.send "+n.channel,
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.String("notification.channel", n.channel),
+			attribute.String("notification.event", string(event.Type)),
+			attribute.Int("user.id", event.User.ID),
+		),
+	)
+	err := n.next.Notify(ctx, event)
+	end(span, err)
+	return err
+}
+
+// Check forwards to the wrapped notifier's health check, if it has one.
+func (n *tracedNotifier) Check(ctx context.Context) error {
+	if checker, ok := n.next.(interface{ Check(context.Context) error }); ok {
+		return checker.Check(ctx)
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package tracing
+
+import (
+	"Curd/model"
+	"Curd/store"
+	"context"
+	"errors"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracedStore is a store.UserStoreInterface that wraps every call to the
+// store it wraps in a span.
+type tracedStore struct {
+	next store.UserStoreInterface
+}
+
+// TraceStore wraps a user store so each method call is a child span of the
+// request that made it. The wrapper also forwards health checks when next
+// supports them.
+func TraceStore(next store.UserStoreInterface) store.UserStoreInterface {
+	return &tracedStore{next: next}
+}
+
+// start begins the span for a store method.
+func (s *tracedStore) start(ctx context.Context, method string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer().Start(ctx, "store."+method, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+}
+
+// endStore ends a store span. A missing user or a stale version is an
+// expected outcome rather than a failure of the store, so it is recorded as
+// an attribute without marking the span as an error.
+func endStore(span trace.Span, err error) {
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		span.SetAttributes(attribute.String("store.outcome", "not_found"))
+		err = nil
+	case errors.Is(err, store.ErrVersionMismatch):
+		span.SetAttributes(attribute.String("store.outcome", "version_mismatch"))
+		err = nil
+	}
+	end(span, err)
+}
+
+// CreateUser traces the wrapped CreateUser.
+func (s *tracedStore) CreateUser(ctx context.Context, user model.User) (model.User, error) {
+	ctx, span := s.start(ctx, "CreateUser")
+	created, err := s.next.CreateUser(ctx, user)
+	span.SetAttributes(attribute.Int("user.id", created.ID))
+	endStore(span, err)
+	return created, err
+}
+
+// GetUser traces the wrapped GetUser.
+func (s *tracedStore) GetUser(ctx context.Context, id int) (model.User, error) {
+	ctx, span := s.start(ctx, "GetUser", attribute.Int("user.id", id))
+	user, err := s.next.GetUser(ctx, id)
+	endStore(span, err)
+	return user, err
+}
+
+// UpdateUser traces the wrapped UpdateUser.
+func (s *tracedStore) UpdateUser(ctx context.Context, id int, user model.User, ifVersion int) (model.User, bool, error) {
+	ctx, span := s.start(ctx, "UpdateUser", attribute.Int("user.id", id), attribute.Int("user.if_version", ifVersion))
+	updated, emailChanged, err := s.next.UpdateUser(ctx, id, user, ifVersion)
+	endStore(span, err)
+	return updated, emailChanged, err
+}
+
+// DeleteUser traces the wrapped DeleteUser.
+func (s *tracedStore) DeleteUser(ctx context.Context, id int, ifVersion int) error {
+	ctx, span := s.start(ctx, "DeleteUser", attribute.Int("user.id", id), attribute.Int("user.if_version", ifVersion))
+	err := s.next.DeleteUser(ctx, id, ifVersion)
+	endStore(span, err)
+	return err
+}
+
+// GetAllUser traces the wrapped GetAllUser.
+func (s *tracedStore) GetAllUser(ctx context.Context) ([]model.User, error) {
+	ctx, span := s.start(ctx, "GetAllUser")
+	users, err := s.next.GetAllUser(ctx)
+	span.SetAttributes(attribute.Int("store.results", len(users)))
+	endStore(span, err)
+	return users, err
+}
+
+// ListUsers traces the wrapped ListUsers.
+func (s *tracedStore) ListUsers(ctx context.Context, opts store.ListOptions) (store.ListResult, error) {
+	ctx, span := s.start(ctx, "ListUsers", attribute.Int("store.limit", opts.Limit), attribute.String("store.sort", string(opts.Sort)))
+	result, err := s.next.ListUsers(ctx, opts)
+	span.SetAttributes(attribute.Int("store.results", len(result.Users)))
+	endStore(span, err)
+	return result, err
+}
+
+// GetUserByEmail traces the wrapped GetUserByEmail. The email is not
+// recorded, since spans are not redacted like logs.
+func (s *tracedStore) GetUserByEmail(ctx context.Context, email string) (model.User, error) {
+	ctx, span := s.start(ctx, "GetUserByEmail")
+	user, err := s.next.GetUserByEmail(ctx, email)
+	span.SetAttributes(attribute.Int("user.id", user.ID))
+	endStore(span, err)
+	return user, err
+}
+
+// SetPassword traces the wrapped SetPassword.
+func (s *tracedStore) SetPassword(ctx context.Context, id int, hash string) error {
+	ctx, span := s.start(ctx, "SetPassword", attribute.Int("user.id", id))
+	err := s.next.SetPassword(ctx, id, hash)
+	endStore(span, err)
+	return err
+}
+
+// VerifyEmail traces the wrapped VerifyEmail.
+func (s *tracedStore) VerifyEmail(ctx context.Context, id int, email string) (model.User, error) {
+	ctx, span := s.start(ctx, "VerifyEmail", attribute.Int("user.id", id))
+	user, err := s.next.VerifyEmail(ctx, id, email)
+	endStore(span, err)
+	return user, err
+}
+
+// Check forwards to the wrapped store's health check, if it has one.
+func (s *tracedStore) Check(ctx context.Context) error {
+	if checker, ok := s.next.(interface{ Check(context.Context) error }); ok {
+		return checker.Check(ctx)
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+// Package tracing sets up OpenTelemetry tracing and instruments the HTTP
+// server, the user store and the notifiers with spans.
+package tracing
+
+import (
+	"Curd/config"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName identifies the spans created by this package.
+const instrumentationName = "Curd"
+
+// tracer returns the tracer of the global provider installed by Setup.
+func tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Setup installs the global tracer provider and the W3C trace context and
+// baggage propagators. It returns a function that flushes pending spans and
+// releases the exporter; call it on shutdown. With the "none" exporter only
+// the propagators are installed, so incoming trace IDs are still forwarded.
+func Setup(ctx context.Context, cfg config.TracingConfig) (shutdown func(context.Context) error, err error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	closeOutput := func() error { return nil }
+	switch cfg.Exporter {
+	case config.ExporterNone, "":
+		return func(context.Context) error { return nil }, nil
+	case config.ExporterOTLP:
+		var opts []otlptracehttp.Option
+		if cfg.Endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpoint(cfg.Endpoint))
+		}
+		if cfg.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		exporter, err = otlptracehttp.New(ctx, opts...)
+	case config.ExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout), stdouttrace.WithPrettyPrint())
+	case config.ExporterFile:
+		var f *os.File
+		if f, err = os.OpenFile(cfg.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644); err != nil {
+			return nil, err
+		}
+		closeOutput = f.Close
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(io.Writer(f))) // One JSON span per line.
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q", cfg.Exporter)
+	}
+	if err != nil {
+		closeOutput()
+		return nil, fmt.Errorf("create %s trace exporter: %w", cfg.Exporter, err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(cfg.ServiceName)))
+	if err != nil {
+		closeOutput()
+		return nil, err
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx) // Flushes the batch before closing the exporter.
+		if cerr := closeOutput(); err == nil {
+			err = cerr
+		}
+		return err
+	}, nil
+}
+
+// Middleware starts a server span for every request, continuing the trace
+// from an incoming traceparent header. Spans are named after the route the
+// request matched (recorded by router.NewRouter), e.g. "GET /users/".
+func Middleware(next http.Handler) http.Handler {
+	routed := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Pattern != "" {
+			trace.SpanFromContext(r.Context()).SetAttributes(semconv.HTTPRoute(r.Pattern))
+		}
+		next.ServeHTTP(w, r)
+	})
+	return otelhttp.NewHandler(routed, "http.server", otelhttp.WithSpanNameFormatter(spanName))
+}
+
+// spanName names a server span after the method and matched route.
+func spanName(_ string, r *http.Request) string {
+	switch {
+	case r.Pattern == "":
+		return r.Method
+	case strings.Contains(r.Pattern, " "):
+		return r.Pattern // The pattern already starts with its method, e.g. "GET /healthz".
+	}
+	return r.Method + " " + r.Pattern
+}
+
+// end records err on the span, if any, and ends it.
+func end(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,152 @@
+// Package validation normalizes and validates structs using declarative
+// struct tags.
+//
+// Two tags are understood:
+//
+//	normalize:"trim,lower"            // applied first, in order, to string fields
+//	validate:"required,email,max=254" // every failing rule is reported
+//
+// Field names in errors come from the json tag, so they match the request body.
+package validation
+
+import (
+	"fmt"
+	"net/mail"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// FieldError describes one field that failed one rule.
+type FieldError struct {
+	Field   string `json:"field"`   // JSON name of the field.
+	Code    string `json:"code"`    // Machine-readable rule name, e.g. "required".
+	Message string `json:"message"` // Human-readable explanation.
+}
+
+// Errors is the list of every failing field returned by Struct.
+type Errors []FieldError
+
+// Error implements the error interface.
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, fe := range e {
+		msgs[i] = fe.Field + ": " + fe.Message
+	}
+	return "validation failed: " + strings.Join(msgs, "; ")
+}
+
+// localePattern matches BCP 47-style language tags such as "en", "pt-BR" or "zh_Hant_TW".
+var localePattern = regexp.MustCompile(`^[A-Za-z]{2,3}([-_][A-Za-z0-9]{2,8})*$`)
+
+// Struct normalizes the string fields of the struct v points to and then
+// checks every validation rule, returning Errors listing all failures or nil.
+func Struct(v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.Elem().Kind() != reflect.Struct {
+		panic("validation: Struct requires a pointer to a struct")
+	}
+	rv = rv.Elem()
+	rt := rv.Type()
+
+	var errs Errors
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		value := rv.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		// Normalize first so rules see the value that will be stored.
+		if value.Kind() == reflect.String {
+			value.SetString(normalize(value.String(), field.Tag.Get("normalize")))
+		}
+
+		if rules := field.Tag.Get("validate"); rules != "" {
+			errs = append(errs, check(jsonName(field), value, rules)...)
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// normalize applies a comma-separated list of normalizers to s.
+func normalize(s, normalizers string) string {
+	for _, n := range strings.Split(normalizers, ",") {
+		switch n {
+		case "trim":
+			s = strings.TrimSpace(s)
+		case "lower":
+			s = strings.ToLower(s)
+		}
+	}
+	return s
+}
+
+// check evaluates a comma-separated list of rules against a field value.
+func check(name string, value reflect.Value, rules string) Errors {
+	var errs Errors
+	for _, rule := range strings.Split(rules, ",") {
+		rule, arg, _ := strings.Cut(rule, "=")
+		if msg := apply(rule, arg, value); msg != "" {
+			errs = append(errs, FieldError{Field: name, Code: rule, Message: msg})
+			if rule == "required" {
+				break // Other rules are meaningless for a missing value.
+			}
+		}
+	}
+	return errs
+}
+
+// apply evaluates one rule, returning an error message or "" if it passes.
+// Rules other than "required" skip empty values, so optional fields may be omitted.
+func apply(rule, arg string, value reflect.Value) string {
+	if rule == "required" {
+		if value.IsZero() {
+			return "is required"
+		}
+		return ""
+	}
+	if value.IsZero() {
+		return ""
+	}
+
+	switch rule {
+	case "email":
+		addr, err := mail.ParseAddress(value.String())
+		if err != nil || addr.Address != value.String() || !strings.Contains(addr.Address[strings.LastIndex(addr.Address, "@"):], ".") {
+			return "must be a valid email address"
+		}
+	case "locale":
+		if !localePattern.MatchString(value.String()) {
+			return "must be a language tag such as en or pt-BR"
+		}
+	case "min", "max":
+		n, err := strconv.Atoi(arg)
+		if err != nil {
+			panic(fmt.Sprintf("validation: bad %s argument %q", rule, arg))
+		}
+		length := utf8.RuneCountInString(value.String())
+		if rule == "min" && length < n {
+			return fmt.Sprintf("must be at least %d characters", n)
+		}
+		if rule == "max" && length > n {
+			return fmt.Sprintf("must be at most %d characters", n)
+		}
+	default:
+		panic(fmt.Sprintf("validation: unknown rule %q", rule))
+	}
+	return ""
+}
+
+// jsonName returns the JSON name of a struct field.
+func jsonName(field reflect.StructField) string {
+	if name, _, _ := strings.Cut(field.Tag.Get("json"), ","); name != "" && name != "-" {
+		return name
+	}
+	return field.Name
+}